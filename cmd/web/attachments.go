@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.tushar.net/internal/blobs"
+	"snippetbox.tushar.net/internal/constants"
+	"snippetbox.tushar.net/internal/models"
+)
+
+const (
+	// maxAttachments is how many files can be uploaded with one snippet.
+	maxAttachments = 5
+
+	// maxFormSize is what the text fields of the create form may take up on
+	// top of the attachments.
+	maxFormSize = 1 << 20
+
+	// uploads larger than this are spooled to temporary files while parsing
+	multipartMemory = 8 << 20
+)
+
+// inlineTypes are the sniffed content types a browser is allowed to show
+// directly, everything else is downloaded. Nothing that can run script.
+var inlineTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "text/plain; charset=utf-8"}
+
+// markupExtensions are always downloaded whatever they sniff as. SVG without
+// an XML declaration sniffs as plain text, and a browser that goes by the
+// name once it's saved would run its script.
+var markupExtensions = []string{".htm", ".html", ".xhtml", ".xht", ".xml", ".svg", ".svgz"}
+
+// inline reports whether a browser may show an attachment in place, see
+// inlineTypes and markupExtensions.
+func inline(a *models.Attachment) bool {
+	if slices.Contains(markupExtensions, strings.ToLower(filepath.Ext(a.Name))) {
+		return false
+	}
+	return slices.Contains(inlineTypes, a.ContentType)
+}
+
+// parseCreateForm parses the create form, which is multipart when it carries
+// attachments. The body is capped at every attachment at its largest plus
+// the text fields; going over is a 413.
+func (app *application) parseCreateForm(w http.ResponseWriter, r *http.Request) bool {
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachments*app.maxAttachmentSize+maxFormSize)
+	err := r.ParseMultipartForm(multipartMemory)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.clientError(w, http.StatusRequestEntityTooLarge)
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return false
+	}
+	return true
+}
+
+// uploads returns the attachments of a parsed create form, without the empty
+// part a file input sends when nothing was picked.
+func uploads(r *http.Request) []*multipart.FileHeader {
+	if r.MultipartForm == nil {
+		return nil
+	}
+	headers := []*multipart.FileHeader{}
+	for _, h := range r.MultipartForm.File["attachments"] {
+		if h.Filename != "" || h.Size > 0 {
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
+
+// checkAttachments validates the uploaded files of the create form.
+func (app *application) checkAttachments(form *snippetCreateForm, headers []*multipart.FileHeader) {
+	form.CheckField(len(headers) <= maxAttachments, "attachments", fmt.Sprintf("No more than %d attachments are allowed", maxAttachments))
+	// the snippet is gone once it's been viewed, its attachments with it
+	form.CheckField(len(headers) == 0 || !form.BurnAfterReading, "attachments", "Burn after reading snippets cannot have attachments")
+	for _, h := range headers {
+		form.CheckField(h.Size <= app.maxAttachmentSize, "attachments", fmt.Sprintf("%s is larger than %s", attachmentName(h.Filename), humanSize(app.maxAttachmentSize)))
+		form.CheckField(len(attachmentName(h.Filename)) <= 255, "attachments", "File names cannot be more than 255 bytes long")
+	}
+}
+
+// attachmentName is the base name of an uploaded file. Browsers send just
+// that, but some older ones send the whole path from the client.
+func attachmentName(filename string) string {
+	name := filepath.Base(strings.ReplaceAll(filename, `\`, "/"))
+	if name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// storeAttachments puts every upload into blob storage, sniffing its content
+// type on the way. If one fails the ones already stored are deleted again.
+func (app *application) storeAttachments(ctx context.Context, headers []*multipart.FileHeader) ([]models.Attachment, error) {
+
+	attachments := []models.Attachment{}
+	for _, h := range headers {
+		a, err := app.storeAttachment(ctx, h)
+		if err != nil {
+			app.deleteBlobs(ctx, attachments)
+			return nil, models.ContextError(ctx, err)
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+func (app *application) storeAttachment(ctx context.Context, h *multipart.FileHeader) (models.Attachment, error) {
+
+	f, err := h.Open()
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	defer f.Close()
+
+	// DetectContentType looks at no more than the first 512 bytes
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return models.Attachment{}, err
+	}
+	head = head[:n]
+
+	key, err := blobs.NewKey()
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if err := app.blobs.Put(ctx, key, io.MultiReader(bytes.NewReader(head), f), h.Size); err != nil {
+		return models.Attachment{}, err
+	}
+	return models.Attachment{
+		Name:        attachmentName(h.Filename),
+		ContentType: http.DetectContentType(head),
+		Size:        h.Size,
+		Key:         key,
+	}, nil
+}
+
+// deleteBlobs removes the blobs of attachments that never made it into the
+// database. It's only a clean up, so failures are logged and not returned. It
+// still runs when ctx was canceled, often the reason they didn't make it.
+func (app *application) deleteBlobs(ctx context.Context, attachments []models.Attachment) {
+	ctx = context.WithoutCancel(ctx)
+	for _, a := range attachments {
+		if err := app.blobs.Delete(ctx, a.Key); err != nil {
+			app.errorLog.Print(err)
+		}
+	}
+}
+
+// snippetAttachment serves an attachment of a snippet, at
+// /s/:slug/attachments/:id.
+func (app *application) snippetAttachment(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) || snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if app.locked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	var attachment *models.Attachment
+	for i := range snippet.Attachments {
+		if snippet.Attachments[i].ID == id {
+			attachment = &snippet.Attachments[i]
+		}
+	}
+	if attachment == nil {
+		app.notFound(w)
+		return
+	}
+
+	blob, err := app.blobs.Get(r.Context(), attachment.Key)
+	if err != nil {
+		if errors.Is(err, blobs.ErrNotFound) {
+			app.errorLog.Printf("blob %s of attachment %d is missing", attachment.Key, attachment.ID)
+			app.notFound(w)
+		} else {
+			app.serverError(w, models.ContextError(r.Context(), err))
+		}
+		return
+	}
+	defer blob.Close()
+
+	disposition := "attachment"
+	if inline(attachment) {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	// FormatMediaType takes care of quoting, and of names that aren't ASCII
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}))
+	if _, err := io.Copy(w, blob); err != nil {
+		// too late for an error page, the headers are out
+		app.errorLog.Print(err)
+	}
+}
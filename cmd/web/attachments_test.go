@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"snippetbox.tushar.net/internal/models"
+)
+
+// stalledBlobs is a blob store that never answers before ctx ends.
+type stalledBlobs struct{}
+
+func (stalledBlobs) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (stalledBlobs) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stalledBlobs) Delete(ctx context.Context, key string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestSnippetAttachmentCutShort(t *testing.T) {
+	app := newTestApplication(t)
+	app.blobs = stalledBlobs{}
+
+	ctx := context.Background()
+	id, slug, err := app.snippetModel.Insert(ctx, models.NewSnippet{
+		Title:       "An old silent pond",
+		Content:     "An old silent pond...",
+		Visibility:  models.VisibilityPublic,
+		Attachments: []models.Attachment{{Name: "pond.txt", ContentType: "text/plain; charset=utf-8", Size: 4, Key: "0123456789abcdef0123456789abcdef"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := app.snippetModel.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urlPath := fmt.Sprintf("/s/%s/attachments/%d", slug, s.Attachments[0].ID)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	timedOut, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode int
+	}{
+		{"Client gone", canceled, statusClientClosedRequest},
+		{"Timed out", timedOut, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, urlPath, nil).WithContext(tt.ctx)
+			app.routes().ServeHTTP(rr, r)
+			if rr.Code != tt.wantCode {
+				t.Errorf("got status %d; want %d", rr.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSnippetAttachment(t *testing.T) {
+	app := newTestApplication(t)
+	owner := newTestServer(t, app.routes())
+	other := owner.newSession(t)
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+	uploads := []upload{
+		{"attachments", "pond.txt", []byte("An old silent pond...")},
+		{"attachments", "frog.png", png},
+		{"attachments", "page.html", []byte("<html><script>alert(1)</script></html>")},
+		{"attachments", "frog.svg", svg},
+		{"attachments", "notes.txt", []byte("<!DOCTYPE html><script>alert(1)</script>")},
+		// a Windows path, only the base name is kept
+		{"attachments", `C:\logs\pond.bin`, []byte{0x00, 0x01, 0x02, 0xff}},
+	}
+	code, header, _ := owner.postMultipart(t, "/snippet/create", snippetForm("An old silent pond", "An old silent pond..."), uploads[:5])
+	if code != http.StatusSeeOther {
+		t.Fatalf("creating with attachments: got status %d; want %d", code, http.StatusSeeOther)
+	}
+	view := header.Get("Location")
+	// over maxAttachments is sent back with the form
+	code, _, _ = owner.postMultipart(t, "/snippet/create", snippetForm("An old silent pond", "An old silent pond..."), append(uploads, uploads[0]))
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("creating with %d attachments: got status %d; want %d", len(uploads)+1, code, http.StatusUnprocessableEntity)
+	}
+	code, header, _ = owner.postMultipart(t, "/snippet/create", snippetForm("A frog jumps", "A frog jumps..."), uploads[5:])
+	if code != http.StatusSeeOther {
+		t.Fatalf("creating with a binary attachment: got status %d; want %d", code, http.StatusSeeOther)
+	}
+	binView := header.Get("Location")
+
+	// the view links every attachment by name
+	links := map[string]string{}
+	linked := regexp.MustCompile(`<a href='(/s/[A-Za-z0-9]+/attachments/\d+)'>([^<]+)</a>`)
+	for _, urlPath := range []string{view, binView} {
+		_, _, body := other.get(t, urlPath)
+		for _, m := range linked.FindAllStringSubmatch(body, -1) {
+			links[m[2]] = m[1]
+		}
+	}
+
+	tests := []struct {
+		name            string
+		file            string
+		wantType        string
+		wantDisposition string
+		wantContent     []byte
+	}{
+		{"Plain text", "pond.txt", "text/plain; charset=utf-8", "inline", uploads[0].content},
+		{"Image", "frog.png", "image/png", "inline", png},
+		{"HTML", "page.html", "text/html; charset=utf-8", "attachment", uploads[2].content},
+		{"SVG sniffed as text", "frog.svg", "text/plain; charset=utf-8", "attachment", svg},
+		{"HTML named as text", "notes.txt", "text/html; charset=utf-8", "attachment", uploads[4].content},
+		{"Binary", "pond.bin", "application/octet-stream", "attachment", uploads[5].content},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlPath, ok := links[tt.file]
+			if !ok {
+				t.Fatalf("the view doesn't link %s, only %v", tt.file, links)
+			}
+			code, header, body := other.get(t, urlPath)
+			if code != http.StatusOK {
+				t.Fatalf("got status %d; want %d", code, http.StatusOK)
+			}
+			if got := header.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("got Content-Type %q; want %q", got, tt.wantType)
+			}
+			disposition, params, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if disposition != tt.wantDisposition || params["filename"] != tt.file {
+				t.Errorf("got Content-Disposition %s, filename %q; want %s, %q", disposition, params["filename"], tt.wantDisposition, tt.file)
+			}
+			if body != strings.TrimSpace(string(tt.wantContent)) {
+				t.Errorf("got content %q; want %q", body, tt.wantContent)
+			}
+		})
+	}
+
+	t.Run("Unknown attachment", func(t *testing.T) {
+		code, _, _ := other.get(t, view+"/attachments/99")
+		if code != http.StatusNotFound {
+			t.Errorf("got status %d; want %d", code, http.StatusNotFound)
+		}
+	})
+}
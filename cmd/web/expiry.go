@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expiryOption is one of the choices offered for how long a new snippet lives.
+type expiryOption struct {
+	Value    string        // form value, like "10m", "7d" or "never"
+	Duration time.Duration // 0 for never
+}
+
+func (o expiryOption) Label() string {
+	if o.Duration == 0 {
+		return "Never"
+	}
+	return humanDuration(o.Duration)
+}
+
+// expiresAtLayout is what a datetime-local input submits, taken to be UTC.
+const expiresAtLayout = "2006-01-02T15:04"
+
+// parseExpiryOptions reads the -expiry-options flag, a comma separated list of
+// durations like "10m,1h,30d" and optionally "never". Days are allowed on top
+// of what time.ParseDuration understands. With a max above 0 every option has
+// to fit within it and never isn't allowed.
+func parseExpiryOptions(list string, max time.Duration) ([]expiryOption, error) {
+	options := []expiryOption{}
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if value == "never" {
+			if max > 0 {
+				return nil, errors.New("expiry option never isn't allowed with a maximum expiry")
+			}
+			options = append(options, expiryOption{Value: value})
+			continue
+		}
+
+		d, err := parseDays(value)
+		if err != nil {
+			return nil, fmt.Errorf("bad expiry option %q: %w", value, err)
+		}
+		if d < time.Minute || d%time.Minute != 0 {
+			return nil, fmt.Errorf("bad expiry option %q: must be a whole number of minutes", value)
+		}
+		if max > 0 && d > max {
+			return nil, fmt.Errorf("expiry option %q is longer than the maximum of %s", value, humanDuration(max))
+		}
+		options = append(options, expiryOption{Value: value, Duration: d})
+	}
+	if len(options) == 0 {
+		return nil, errors.New("no expiry options")
+	}
+	return options, nil
+}
+
+// parseDays is time.ParseDuration plus a "d" suffix for whole days.
+func parseDays(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, errors.New("invalid number of days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// defaultExpiry is the option picked on a blank create form: a year if that's
+// on offer, like before expiry was configurable, otherwise the first one.
+func defaultExpiry(options []expiryOption) string {
+	for _, o := range options {
+		if o.Duration == 365*24*time.Hour {
+			return o.Value
+		}
+	}
+	return options[0].Value
+}
+
+// expiresAt works out when a new snippet expires from the create form: one of
+// the configured options, or "at" with an explicit date and time in at. The
+// result is rounded to the minute and zero for never. The error is meant to be
+// shown next to the field.
+func (app *application) expiresAt(value string, at string, now time.Time) (time.Time, error) {
+	if value != "at" {
+		for _, o := range app.expiryOptions {
+			if o.Value == value {
+				if o.Duration == 0 {
+					return time.Time{}, nil
+				}
+				return now.Add(o.Duration).Round(time.Minute), nil
+			}
+		}
+		return time.Time{}, errors.New("This field must be one of the listed options")
+	}
+
+	t, err := time.Parse(expiresAtLayout, at)
+	if err != nil {
+		return time.Time{}, errors.New("Enter a date and time")
+	}
+	if t.Sub(now) < time.Minute {
+		return time.Time{}, errors.New("This field must be at least a minute in the future")
+	}
+	if app.maxExpiry > 0 && t.Sub(now) > app.maxExpiry {
+		return time.Time{}, fmt.Errorf("This field cannot be more than %s from now", humanDuration(app.maxExpiry))
+	}
+	return t, nil
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"snippetbox.tushar.net/internal/constants"
+	"snippetbox.tushar.net/internal/diff"
+	"snippetbox.tushar.net/internal/models"
+	"snippetbox.tushar.net/internal/syntax"
 	"snippetbox.tushar.net/internal/validator"
 )
 
@@ -17,7 +28,7 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// 	return
 	// }
 
-	snippets, err := app.snippetModel.Latest()
+	snippets, err := app.snippetModel.Latest(r.Context())
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -29,20 +40,245 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	// Latest returns a full page when there may be older snippets to browse
+	if len(snippets) == defaultPageSize {
+		data.Page = &pageLinks{
+			Next: fmt.Sprintf("/snippets?before=%d", snippets[len(snippets)-1].ID),
+		}
+	}
 
 	// helper to render the tmpl-page passed
 	app.render(w, http.StatusOK, "home.tmpl", data)
 }
 
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+	maxSearchResult = 50
+)
+
+// snippetList pages through every live snippet, newest first.
+//
+//	/snippets?before=42   snippets older than #42
+//	/snippets?after=42    snippets newer than #42
+//	/snippets?size=50     page size, capped at maxPageSize
+func (app *application) snippetList(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	size, err := queryInt(query, "size", defaultPageSize)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	before, err := queryInt(query, "before", 0)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	after, err := queryInt(query, "after", 0)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	size = min(max(size, 1), maxPageSize)
+
+	// /tag/:name and /snippets?tag= list the same thing, pagination links
+	// stay on whichever form the page was reached through
+	base := "/snippets"
+	tag := httprouter.ParamsFromContext(r.Context()).ByName("name")
+	if tag != "" {
+		base = "/tag/" + url.PathEscape(tag)
+	} else {
+		tag = query.Get("tag")
+	}
+	tag = strings.ToLower(strings.TrimSpace(tag))
+
+	snippets, more, err := app.snippetModel.Page(r.Context(), tag, before, after, size)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// more only tells us about the direction we paged in, the other direction
+	// has snippets whenever we came from a cursor
+	link := func(key string, id int) string {
+		q := url.Values{}
+		q.Set(key, strconv.Itoa(id))
+		if size != defaultPageSize {
+			q.Set("size", strconv.Itoa(size))
+		}
+		if tag != "" && base == "/snippets" {
+			q.Set("tag", tag)
+		}
+		return base + "?" + q.Encode()
+	}
+	page := &pageLinks{}
+	if len(snippets) > 0 {
+		if (after == 0 && more) || after > 0 {
+			page.Next = link("before", snippets[len(snippets)-1].ID)
+		}
+		if (after > 0 && more) || before > 0 {
+			page.Prev = link("after", snippets[0].ID)
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Page = page
+	data.Tag = tag
+	app.render(w, http.StatusOK, "home.tmpl", data)
+}
+
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 
+	params := httprouter.ParamsFromContext(r.Context())
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) {
+		app.notFound(w)
+		return
+	}
+	id := snippet.ID
+
+	if app.locked(r, snippet) {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetUnlockForm{}
+		app.render(w, http.StatusOK, "unlock.tmpl", data)
+		return
+	}
+
+	if snippet.BurnAfterReading {
+		// Burn deletes the snippet, so of concurrent viewers only one gets it
+		tags, files := snippet.Tags, snippet.Files
+		snippet, err = app.snippetModel.Burn(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, constants.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+		snippet.Tags, snippet.Files = tags, files
+
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		app.render(w, http.StatusOK, "view.tmpl", data)
+		return
+	}
+
+	revisions, err := app.snippetModel.Revisions(r.Context(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	forks, err := app.snippetModel.Forks(r.Context(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	parent, err := app.parent(r, snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// helper method to get the common dynamic data
+	data := app.newTemplateData(r)
+	// handler or api specific data
+	data.Snippet = snippet
+	data.Revisions = revisions
+	data.Forks = forks
+	data.Parent = parent
+	data.Owned = app.owns(r, snippet)
+
+	// helper to render the tmpl-page passed.
+	app.render(w, http.StatusOK, "view.tmpl", data)
+}
+
+// snippetDownload sends every file of a snippet in one zip archive, at
+// /snippet/download/:slug.zip.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+
+	slug, ok := strings.CutSuffix(httprouter.ParamsFromContext(r.Context()).ByName("file"), ".zip")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), slug)
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) {
+		app.notFound(w)
+		return
+	}
+	// the content of a burn after reading snippet is only shown by snippetView
+	if snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if app.locked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	// built in memory first, so a failure can still become a 500
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, file := range snippet.AllFiles() {
+		name := file.Name
+		if name == "" {
+			// only a single file can be without a name
+			name = snippet.Slug + ".txt"
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: snippet.Created})
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if _, err := io.WriteString(fw, file.Content); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, snippet.Slug))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
+
+// snippetViewRedirect sends the numeric urls snippets used to have to their
+// slug urls. Only public snippets are redirected, otherwise counting through
+// ids would still find unlisted and private ones, and burn after reading ones
+// that anybody could then burn.
+func (app *application) snippetViewRedirect(w http.ResponseWriter, r *http.Request) {
+
 	params := httprouter.ParamsFromContext(r.Context())
 	id, err := strconv.Atoi(params.ByName("id"))
 	if err != nil || id < 0 {
 		app.notFound(w)
 		return
 	}
-	snippet, err := app.snippetModel.Get(id)
+	snippet, err := app.snippetModel.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, constants.ErrNoRecord) {
 			app.notFound(w)
@@ -51,13 +287,74 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if snippet.Visibility != models.VisibilityPublic || snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+
+	target := fmt.Sprintf("/s/%s", snippet.Slug)
+	if n := params.ByName("n"); n != "" {
+		target += "/rev/" + n
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+// shows an older revision of a snippet in the normal view layout
+func (app *application) snippetRevisionView(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	n, err := strconv.Atoi(params.ByName("n"))
+	if err != nil || n < 1 {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) {
+		app.notFound(w)
+		return
+	}
+	id := snippet.ID
+	// the content of a burn after reading snippet is only shown by snippetView
+	if snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if app.locked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+	revision, err := app.snippetModel.GetRevision(r.Context(), id, n)
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	revisions, err := app.snippetModel.Revisions(r.Context(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// show the old title and content, the rest (expiry etc) is per snippet
+	snippet.Title = revision.Title
+	snippet.Content = revision.Content
 
-	// helper method to get the common dynamic data
 	data := app.newTemplateData(r)
-	// handler or api specific data
 	data.Snippet = snippet
-
-	// helper to render the tmpl-page passed.
+	data.Revision = revision
+	data.Revisions = revisions
 	app.render(w, http.StatusOK, "view.tmpl", data)
 }
 
@@ -66,7 +363,8 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Expires:    defaultExpiry(app.expiryOptions),
+		Visibility: models.VisibilityPublic,
 	}
 	app.render(w, http.StatusOK, "create.tmpl", data)
 	// w.Write([]byte("Display the form for reating a snippet..."))
@@ -74,14 +372,83 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 
 // represent the form data entered + validator
 type snippetCreateForm struct {
-	Title   string `form:"title"`
-	Content string `form:"content"`
-	Expires int    `form:"expires"`
+	Title            string     `form:"title"`
+	Content          string     `form:"content"`
+	Expires          string     `form:"expires"`    // an expiry option, or "at" for ExpiresAt
+	ExpiresAt        string     `form:"expires_at"` // see expiresAtLayout
+	Tags             string     `form:"tags"`       // comma separated
+	Language         string     `form:"language"`
+	BurnAfterReading bool       `form:"burn"`
+	Password         string     `form:"password"` // optional
+	Visibility       string     `form:"visibility"`
+	Fork             string     `form:"fork"`        // slug of the snippet being forked, if any
+	Filename         string     `form:"filename"`    // name of Content, needed once there are more files
+	Files            []fileForm `form:"files"`       // files after the first, files[0].name etc
+	AddFile          bool       `form:"add_file"`    // the add file button was pressed
+	RemoveFile       *int       `form:"remove_file"` // index into Files of a remove button that was pressed
 	// struct embedding : re-usability with composition
 	// embedding the struct inside another struct
 	validator.Validator `form:"-"` // struct tag `form:"-"` used to tell decoder to ignore field during decoding
 }
 
+// snippetFork shows the create form filled in with a copy of a snippet, which
+// is recorded as the new snippet's parent once it's published.
+func (app *application) snippetFork(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) {
+		app.notFound(w)
+		return
+	}
+	// copying it would get around the burn
+	if snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if app.locked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	form := snippetCreateForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Expires:    defaultExpiry(app.expiryOptions),
+		Tags:       strings.Join(snippet.Tags, ", "),
+		Visibility: models.VisibilityPublic,
+		Filename:   snippet.Filename,
+		Fork:       snippet.Slug,
+	}
+	// a guess is made again for the copy
+	if !snippet.Guessed {
+		form.Language = snippet.Language
+	}
+	for _, file := range snippet.Files {
+		form.Files = append(form.Files, fileForm{Name: file.Name, Language: file.Language, Content: file.Content})
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Source = snippet
+	app.render(w, http.StatusOK, "create.tmpl", data)
+}
+
+// fileForm is one of the extra files on the create form.
+type fileForm struct {
+	Name     string `form:"name"`
+	Language string `form:"language"`
+	Content  string `form:"content"`
+}
+
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
 
 	// if r.Method != http.MethodPost {
@@ -99,39 +466,727 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	// return
 	// }
 
-	err := r.ParseForm()
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+	if !app.parseCreateForm(w, r) {
 		return
 	}
 
 	var form snippetCreateForm
-	err = app.decodePostForm(r, &form)
+	err := app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
+
+	// a fork whose source has gone in the meantime is published as an
+	// original, it's only the link back that's lost
+	var source *models.Snippet
+	if form.Fork != "" {
+		source, err = app.snippetModel.GetBySlug(r.Context(), form.Fork)
+		if err != nil && !errors.Is(err, constants.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+		if source != nil && (app.hidden(r, source) || source.BurnAfterReading || app.locked(r, source)) {
+			source = nil
+		}
+		if source == nil {
+			form.Fork = ""
+		}
+	}
+
+	// the add and remove file buttons submit the whole form, which comes back
+	// with a file more or less and nothing saved
+	if form.AddFile || form.RemoveFile != nil {
+		if form.AddFile && len(form.Files) < maxFiles-1 {
+			form.Files = append(form.Files, fileForm{})
+		}
+		if i := form.RemoveFile; i != nil && *i >= 0 && *i < len(form.Files) {
+			form.Files = slices.Delete(form.Files, *i, *i+1)
+		}
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.Source = source
+		app.render(w, http.StatusOK, "create.tmpl", data)
+		return
+	}
+
 	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
 	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
 	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedInt(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.CheckField(len(form.Content) <= maxContentSize, "content", "This field cannot be more than 512 KB long")
+	expires, err := app.expiresAt(form.Expires, form.ExpiresAt, time.Now().UTC())
+	if err != nil {
+		form.AddFieldError("expires", err.Error())
+	}
+	form.CheckField(form.Language == "" || syntax.Supported(form.Language), "language", "This field must be one of the listed languages")
+	form.CheckField(form.Password == "" || validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	// bcrypt only looks at the first 72 bytes
+	form.CheckField(len(form.Password) <= 72, "password", "This field cannot be more than 72 bytes long")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "This field must be public, unlisted or private")
+	tags := splitTags(form.Tags)
+	form.CheckField(validator.MaxItems(tags, maxTags), "tags", fmt.Sprintf("No more than %d tags are allowed", maxTags))
+	form.CheckField(validator.All(tags, func(tag string) bool { return validator.MaxChars(tag, 20) }), "tags", "Tags cannot be more than 20 characters long")
+	form.CheckField(validator.All(tags, func(tag string) bool { return validator.Matches(tag, validator.TagRX) }), "tags", "Tags may only contain letters, digits and . _ + -")
+	checkFiles(&form)
+	headers := uploads(r)
+	app.checkAttachments(&form, headers)
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
+		data.Source = source
 		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
 		return
 	}
 
-	id, err := app.snippetModel.Insert(form.Title, form.Content, form.Expires)
+	owner, err := app.ownerToken(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	attachments, err := app.storeAttachments(r.Context(), headers)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// a file name says more about the language than the title does
+	name := form.Title
+	if form.Filename != "" {
+		name = form.Filename
+	}
+	language, guessed := pickLanguage(form.Language, name, form.Content)
+	files := []models.File{}
+	for _, file := range form.Files {
+		// only the first file's guess is remembered, it's the one that can be edited
+		language, _ := pickLanguage(file.Language, file.Name, file.Content)
+		files = append(files, models.File{Name: file.Name, Language: language, Content: file.Content})
+	}
+	n := models.NewSnippet{
+		Title:    form.Title,
+		Content:  form.Content,
+		Expires:  expires,
+		Tags:     tags,
+		Language: language,
+		Guessed:  guessed,
+
+		BurnAfterReading: form.BurnAfterReading,
+		Password:         form.Password,
+
+		Visibility: form.Visibility,
+		Owner:      owner,
+
+		Filename: form.Filename,
+		Files:    files,
+
+		Attachments: attachments,
+	}
+	if source != nil {
+		n.ForkedFrom = source.ID
+	}
+	_, slug, err := app.snippetModel.Insert(r.Context(), n)
 	if err != nil {
+		app.deleteBlobs(r.Context(), attachments)
 		app.serverError(w, err)
 		return
 	}
 
+	// following the usual redirect would burn the snippet straight away, so
+	// the link to share is shown instead. It's never built from the Host
+	// header, which the client picks.
+	if form.BurnAfterReading {
+		data := app.newTemplateData(r)
+		data.Link = fmt.Sprintf("%s/s/%s", app.baseURL, slug)
+		app.render(w, http.StatusCreated, "created.tmpl", data)
+		return
+	}
+
 	// snippet is created successfully in db
 	// then we can store data in the session with key = flash
+	app.wrote(r)
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", slug), http.StatusSeeOther)
+}
+
+const maxTags = 5
+
+// maxContentSize is the most bytes a snippet's content, or one of its files,
+// may have. Highlighting and diffs are done on every view.
+const maxContentSize = 512 << 10
+
+// splitTags turns the comma separated tags field into a list of lower case
+// tags, dropping empty entries and duplicates.
+func splitTags(field string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(field, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// maxFiles is how many files one snippet can hold, the first included.
+const maxFiles = 10
+
+// checkFiles validates the file names and the extra files of the create form.
+// Names are optional for a single file, with more they're needed to tell the
+// files apart and to name them in the zip download.
+func checkFiles(form *snippetCreateForm) {
+	names := []string{}
+	check := func(key, name string) {
+		if name == "" && len(form.Files) == 0 {
+			return
+		}
+		form.CheckField(validator.NotBlank(name), key, "Every file needs a name")
+		form.CheckField(validator.MaxChars(name, 100), key, "File names cannot be more than 100 characters long")
+		form.CheckField(validator.Matches(name, validator.FileNameRX), key, "File names may only contain letters, digits and . _ + -")
+		form.CheckField(!slices.Contains(names, name), key, "Another file already has this name")
+		names = append(names, name)
+	}
+
+	check("filename", form.Filename)
+	form.CheckField(validator.MaxItems(form.Files, maxFiles-1), "files", fmt.Sprintf("No more than %d files are allowed", maxFiles))
+	for i, file := range form.Files {
+		key := fmt.Sprintf("files.%d", i)
+		check(key, file.Name)
+		form.CheckField(validator.NotBlank(file.Content), key, "This file cannot be empty")
+		form.CheckField(len(file.Content) <= maxContentSize, key, "This file cannot be more than 512 KB long")
+		form.CheckField(file.Language == "" || syntax.Supported(file.Language), key, "The language must be one of the listed ones")
+	}
+}
+
+// pickLanguage returns the language the author picked, or a guess from the
+// title and content if they left it on "Detect automatically".
+func pickLanguage(picked, title, content string) (string, bool) {
+	if picked != "" {
+		return picked, false
+	}
+	return syntax.Detect(title, content), true
+}
+
+// represent the edit form, expiry can't be changed once a snippet is created
+type snippetEditForm struct {
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	Language            string `form:"language"` // empty to detect it again
+	validator.Validator `form:"-"`
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) {
+		app.notFound(w)
+		return
+	}
+	// only the session that created a snippet may change it
+	if !app.owns(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	// the content of a burn after reading snippet is only shown by snippetView
+	if snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if app.locked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	form := snippetEditForm{
+		Title:   snippet.Title,
+		Content: snippet.Content,
+	}
+	// a guess is made again on save unless the author picks something
+	if !snippet.Guessed {
+		form.Language = snippet.Language
+	}
+	data.Form = form
+	app.render(w, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) {
+		app.notFound(w)
+		return
+	}
+	// only the session that created a snippet may change it
+	if !app.owns(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	id := snippet.ID
+	// the content of a burn after reading snippet is only shown by snippetView
+	if snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if app.locked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	var form snippetEditForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(len(form.Content) <= maxContentSize, "content", "This field cannot be more than 512 KB long")
+	form.CheckField(form.Language == "" || syntax.Supported(form.Language), "language", "This field must be one of the listed languages")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	language, guessed := pickLanguage(form.Language, form.Title, form.Content)
+	_, err = app.snippetModel.Update(r.Context(), id, form.Title, form.Content, language, guessed)
+	if err != nil {
+		// the snippet can expire between the Get above and the update
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.wrote(r)
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+// snippetDiff renders a line diff between two revisions of a snippet, or
+// between this snippet and another one when ?with= is given.
+//
+//	/snippet/diff/aB3?from=2&to=3      revisions 2 and 3 of aB3
+//	/snippet/diff/aB3?with=xY7         latest of aB3 against latest of xY7
+//	/snippet/diff/aB3?view=split       side-by-side instead of unified
+//	/snippet/diff/aB3?format=diff      download as a text/x-diff file
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	query := r.URL.Query()
+
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) {
+		app.notFound(w)
+		return
+	}
+	// the content of a burn after reading snippet is only shown by snippetView
+	if snippet.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if app.locked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+	other := snippet
+	if query.Has("with") {
+		other, err = app.snippetModel.GetBySlug(r.Context(), query.Get("with"))
+		if err != nil {
+			if errors.Is(err, constants.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+		if other.BurnAfterReading || app.hidden(r, other) {
+			app.notFound(w)
+			return
+		}
+		if app.locked(r, other) {
+			http.Redirect(w, r, fmt.Sprintf("/s/%s", other.Slug), http.StatusSeeOther)
+			return
+		}
+	}
+
+	// by default compare the latest revision with the one before it, or the
+	// latest revisions of the two snippets
+	from, to := max(snippet.Revision-1, 1), other.Revision
+	if other.ID != snippet.ID {
+		from = snippet.Revision
+	}
+	if query.Has("from") {
+		if from, err = strconv.Atoi(query.Get("from")); err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	if query.Has("to") {
+		if to, err = strconv.Atoi(query.Get("to")); err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	fromRev, err := app.snippetModel.GetRevision(r.Context(), snippet.ID, from)
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	toRev, err := app.snippetModel.GetRevision(r.Context(), other.ID, to)
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.renderDiff(w, r, snippet.Slug, fromRev, other.Slug, toRev)
+}
+
+func (app *application) renderDiff(w http.ResponseWriter, r *http.Request, fromSlug string, from *models.Revision, toSlug string, to *models.Revision) {
+
+	query := r.URL.Query()
+	fromName := fmt.Sprintf("snippet-%s@%d", fromSlug, from.Number)
+	toName := fmt.Sprintf("snippet-%s@%d", toSlug, to.Number)
+	// past diff's limits the page says so, rather than tie up the server
+	lines, err := diff.Lines(from.Content, to.Content)
+	tooLarge := errors.Is(err, diff.ErrTooLarge)
+	hunks := diff.Hunks(lines, 3)
+
+	if query.Get("format") == "diff" {
+		if tooLarge {
+			app.clientError(w, http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s_%s.diff"`, fromName, toName))
+		w.Write([]byte(diff.Unified(fromName, toName, hunks)))
+		return
+	}
+
+	view := "unified"
+	if query.Get("view") == "split" {
+		view = "split"
+	}
+	// links to the other layouts keep from/to/with as they are
+	link := func(key, value string) string {
+		q := r.URL.Query()
+		q.Set(key, value)
+		return r.URL.Path + "?" + q.Encode()
+	}
+
+	data := app.newTemplateData(r)
+	data.Diff = &diffData{
+		From:        from,
+		To:          to,
+		FromSlug:    fromSlug,
+		ToSlug:      toSlug,
+		View:        view,
+		Hunks:       hunks,
+		TooLarge:    tooLarge,
+		UnifiedURL:  link("view", "unified"),
+		SplitURL:    link("view", "split"),
+		DownloadURL: link("format", "diff"),
+	}
+	app.render(w, http.StatusOK, "diff.tmpl", data)
+}
+
+// moves a snippet to the trash, it can be restored from /snippet/trash until
+// the grace period runs out
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	// only the owner may delete a snippet
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) {
+		app.notFound(w)
+		return
+	}
+	if !app.owns(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	id := snippet.ID
+
+	err = app.snippetModel.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.wrote(r)
+	app.sessionManager.Put(r.Context(), "flash", "Snippet moved to trash.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) snippetTrash(w http.ResponseWriter, r *http.Request) {
+
+	owner := app.sessionManager.GetString(r.Context(), "owner")
+	snippets, err := app.snippetModel.Trash(r.Context(), app.trashGrace, owner)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.TrashGrace = app.trashGrace
+	app.render(w, http.StatusOK, "trash.tmpl", data)
+}
+
+func (app *application) snippetRestorePost(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	slug := params.ByName("slug")
+
+	// only the owner's, the same snippets the trash lists
+	owner := app.sessionManager.GetString(r.Context(), "owner")
+	_, err := app.snippetModel.Restore(r.Context(), slug, app.trashGrace, owner)
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else if errors.Is(err, constants.ErrNotOwner) {
+			app.clientError(w, http.StatusForbidden)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.wrote(r)
+	app.sessionManager.Put(r.Context(), "flash", "Snippet restored!")
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", slug), http.StatusSeeOther)
+}
+
+// search lists live snippets matching ?q=, with title matches ranked first.
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query().Get("q")
+
+	snippets := []*models.Snippet{}
+	if strings.TrimSpace(query) != "" {
+		var err error
+		snippets, err = app.snippetModel.Search(r.Context(), query, maxSearchResult)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Search = &searchData{
+		Query: query,
+		Terms: models.SearchTerms(query),
+	}
+	app.render(w, http.StatusOK, "search.tmpl", data)
+}
+
+// highlightCSS serves the stylesheet for a highlighting style, the route
+// param is the file name, e.g. monokai.css.
+func (app *application) highlightCSS(w http.ResponseWriter, r *http.Request) {
+
+	name, ok := strings.CutSuffix(httprouter.ParamsFromContext(r.Context()).ByName("style"), ".css")
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	css, err := syntax.CSS(name)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(css)
+}
+
+// stylePost remembers the highlighting style in the session and sends the
+// user back to the page the picker was on.
+func (app *application) stylePost(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	style := r.PostForm.Get("style")
+	if !slices.Contains(syntax.Styles, style) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "style", style)
+
+	// only redirect to paths on this site
+	back := r.PostForm.Get("back")
+	if !strings.HasPrefix(back, "/") || strings.HasPrefix(back, "//") || strings.HasPrefix(back, "/\\") {
+		back = "/"
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+const (
+	maxUnlockFailuresPerIP      = 10
+	maxUnlockFailuresPerSnippet = 20
+	unlockWindow                = 15 * time.Minute
+)
+
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// snippetUnlockPost checks the password of a protected snippet and remembers
+// it in the session when it's right. Failures are limited per client IP and
+// per snippet, so a password can't be guessed quickly from one address or
+// spread over many.
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	snippet, err := app.snippetModel.GetBySlug(r.Context(), params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if app.hidden(r, snippet) {
+		app.notFound(w)
+		return
+	}
+	id := snippet.ID
+
+	var form snippetUnlockForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// both limits are taken before the slow password check and given back
+	// unless the password turns out wrong
+	ipKey, snippetKey := clientIP(r), strconv.Itoa(id)
+	okIP, wait := app.ipUnlockLimiter.Reserve(ipKey)
+	okSnippet := false
+	if okIP {
+		okSnippet, wait = app.snippetUnlockLimiter.Reserve(snippetKey)
+		if !okSnippet {
+			app.ipUnlockLimiter.Refund(ipKey)
+		}
+	}
+	if !okIP || !okSnippet {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		form.AddFieldError("password", "Too many wrong passwords, try again later")
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "unlock.tmpl", data)
+		return
+	}
+
+	err = app.snippetModel.CheckPassword(r.Context(), id, form.Password)
+	if !errors.Is(err, constants.ErrInvalidCredentials) {
+		app.ipUnlockLimiter.Refund(ipKey)
+		app.snippetUnlockLimiter.Refund(snippetKey)
+	}
+	if err != nil {
+		if errors.Is(err, constants.ErrInvalidCredentials) {
+			form.AddFieldError("password", "Wrong password")
+			data := app.newTemplateData(r)
+			data.Snippet = snippet
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "unlock.tmpl", data)
+		} else if errors.Is(err, constants.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// new privileges, new session token
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), unlockKey(id), true)
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+// cacheStats reports the hit and miss counters of the snippet cache as json,
+// {"hits":12,"misses":3}.
+func (app *application) cacheStats(w http.ResponseWriter, r *http.Request) {
+
+	if app.cache == nil {
+		app.notFound(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(app.cache.Stats()); err != nil {
+		app.errorLog.Print(err)
+	}
 }
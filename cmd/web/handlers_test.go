@@ -0,0 +1,496 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"snippetbox.tushar.net/internal/models"
+)
+
+// snippetForm is a valid create form with the given title and content.
+func snippetForm(title, content string) url.Values {
+	form := url.Values{}
+	form.Add("title", title)
+	form.Add("content", content)
+	form.Add("expires", "1d")
+	form.Add("visibility", models.VisibilityPublic)
+	return form
+}
+
+// createSnippet creates a snippet through the create form and returns the
+// url it redirects to.
+func (ts *testServer) createSnippet(t *testing.T, form url.Values) string {
+	t.Helper()
+
+	code, header, _ := ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("creating a snippet: got status %d; want %d", code, http.StatusSeeOther)
+	}
+	return header.Get("Location")
+}
+
+func TestSnippetCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name     string
+		title    string
+		content  string
+		expires  string
+		wantCode int
+	}{
+		{"Valid", "An old silent pond", "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.", "1d", http.StatusSeeOther},
+		{"Blank title", "", "A frog jumps", "1d", http.StatusUnprocessableEntity},
+		{"Blank content", "An old silent pond", "", "1d", http.StatusUnprocessableEntity},
+		{"Content too long", "An old silent pond", strings.Repeat("a", maxContentSize+1), "1d", http.StatusUnprocessableEntity},
+		{"Unknown expiry", "An old silent pond", "A frog jumps", "5y", http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := snippetForm(tt.title, tt.content)
+			form.Set("expires", tt.expires)
+
+			code, header, _ := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d", code, tt.wantCode)
+			}
+			if code != http.StatusSeeOther {
+				return
+			}
+
+			location := header.Get("Location")
+			code, _, body := ts.get(t, location)
+			if code != http.StatusOK {
+				t.Fatalf("got status %d viewing the new snippet at %q; want %d", code, location, http.StatusOK)
+			}
+			if !strings.Contains(body, tt.title) {
+				t.Errorf("the new snippet's page doesn't have its title %q", tt.title)
+			}
+		})
+	}
+}
+
+func TestSnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	snippet := ts.createSnippet(t, snippetForm("An old silent pond", "An old silent pond..."))
+	form := snippetForm("A frog jumps", "A frog jumps into the pond")
+	form.Set("visibility", models.VisibilityPrivate)
+	private := ts.newSession(t).createSnippet(t, form)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Existing", snippet, http.StatusOK, "An old silent pond..."},
+		{"Private to another session", private, http.StatusNotFound, ""},
+		{"Unknown id", "/snippet/view/99", http.StatusNotFound, ""},
+		{"Invalid id", "/snippet/view/foo", http.StatusNotFound, ""},
+		{"Unknown route", "/missing", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body doesn't contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetViewRedirect(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	public := ts.createSnippet(t, snippetForm("An old silent pond", "An old silent pond..."))
+
+	// a burn after reading snippet shows its link instead of redirecting
+	form := snippetForm("A frog jumps", "A frog jumps into the pond")
+	form.Set("burn", "true")
+	code, _, body := ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusCreated {
+		t.Fatalf("creating a burn after reading snippet: got status %d; want %d", code, http.StatusCreated)
+	}
+	burn := regexp.MustCompile(`/s/[A-Za-z0-9]+`).FindString(body)
+	if burn == "" {
+		t.Fatal("no link on the page of the new burn after reading snippet")
+	}
+
+	code, header, _ := ts.get(t, "/snippet/view/1")
+	if code != http.StatusMovedPermanently || header.Get("Location") != public {
+		t.Errorf("public snippet: got status %d to %q; want %d to %q", code, header.Get("Location"), http.StatusMovedPermanently, public)
+	}
+	code, _, _ = ts.get(t, "/snippet/view/2")
+	if code != http.StatusNotFound {
+		t.Errorf("burn after reading snippet: got status %d; want %d", code, http.StatusNotFound)
+	}
+
+	// and it's still there for the person it was meant for
+	code, _, body = ts.get(t, burn)
+	if code != http.StatusOK || !strings.Contains(body, "A frog jumps into the pond") {
+		t.Errorf("viewing the burn after reading snippet: got status %d; want %d with its content", code, http.StatusOK)
+	}
+}
+
+func TestSnippetViewBurn(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	form := snippetForm("A frog jumps", "A frog jumps into the pond")
+	form.Set("burn", "true")
+	_, _, body := ts.postForm(t, "/snippet/create", form)
+	link := regexp.MustCompile(`/s/[A-Za-z0-9]+`).FindString(body)
+	if link == "" {
+		t.Fatal("no link on the page of the new burn after reading snippet")
+	}
+
+	code, _, body := ts.newSession(t).get(t, link)
+	if code != http.StatusOK || !strings.Contains(body, "A frog jumps into the pond") {
+		t.Fatalf("first view: got status %d; want %d with the content", code, http.StatusOK)
+	}
+	code, _, body = ts.newSession(t).get(t, link)
+	if code != http.StatusNotFound || strings.Contains(body, "A frog jumps into the pond") {
+		t.Errorf("second view: got status %d; want %d without the content", code, http.StatusNotFound)
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	app := newTestApplication(t)
+	owner := newTestServer(t, app.routes())
+	other := owner.newSession(t)
+
+	create := func(title, visibility string) string {
+		form := snippetForm(title, "An old silent pond...")
+		form.Set("visibility", visibility)
+		form.Set("tags", "haiku")
+		return owner.createSnippet(t, form)
+	}
+	public := create("Public pond", models.VisibilityPublic)
+	unlisted := create("Unlisted pond", models.VisibilityUnlisted)
+	private := create("Private pond", models.VisibilityPrivate)
+
+	t.Run("Listings", func(t *testing.T) {
+		for _, urlPath := range []string{"/", "/snippets", "/search?q=pond", "/tag/haiku"} {
+			for _, ts := range []*testServer{owner, other} {
+				code, _, body := ts.get(t, urlPath)
+				if code != http.StatusOK {
+					t.Fatalf("%s: got status %d; want %d", urlPath, code, http.StatusOK)
+				}
+				// search marks up the titles, the links are left alone
+				if !strings.Contains(body, public) {
+					t.Errorf("%s doesn't list the public snippet", urlPath)
+				}
+				if strings.Contains(body, unlisted) {
+					t.Errorf("%s lists the unlisted snippet", urlPath)
+				}
+				if strings.Contains(body, private) {
+					t.Errorf("%s lists the private snippet", urlPath)
+				}
+			}
+		}
+	})
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		urlPath  string
+		wantCode int
+	}{
+		{"Public to another session", other, public, http.StatusOK},
+		{"Unlisted to another session", other, unlisted, http.StatusOK},
+		{"Private to another session", other, private, http.StatusNotFound},
+		{"Private to its owner", owner, private, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := tt.ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestOwnerOnlyWrites(t *testing.T) {
+	app := newTestApplication(t)
+	owner := newTestServer(t, app.routes())
+	other := owner.newSession(t)
+
+	public := owner.createSnippet(t, snippetForm("An old silent pond", "An old silent pond..."))
+	slug := path.Base(public)
+	form := snippetForm("A frog jumps", "A frog jumps into the pond")
+	form.Set("visibility", models.VisibilityUnlisted)
+	unlisted := path.Base(owner.createSnippet(t, form))
+	form.Set("visibility", models.VisibilityPrivate)
+	private := path.Base(owner.createSnippet(t, form))
+
+	edit := url.Values{"title": {"Defaced"}, "content": {"Defaced"}}
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		method   string
+		urlPath  string
+		form     url.Values
+		wantCode int
+	}{
+		{"Edit form", other, http.MethodGet, "/snippet/edit/" + slug, nil, http.StatusForbidden},
+		{"Edit", other, http.MethodPost, "/snippet/edit/" + slug, edit, http.StatusForbidden},
+		{"Edit unlisted", other, http.MethodPost, "/snippet/edit/" + unlisted, edit, http.StatusForbidden},
+		{"Edit private", other, http.MethodPost, "/snippet/edit/" + private, edit, http.StatusNotFound},
+		{"Delete", other, http.MethodPost, "/snippet/delete/" + slug, url.Values{}, http.StatusForbidden},
+		{"Delete unlisted", other, http.MethodPost, "/snippet/delete/" + unlisted, url.Values{}, http.StatusForbidden},
+		{"Delete private", other, http.MethodPost, "/snippet/delete/" + private, url.Values{}, http.StatusNotFound},
+		{"Edit form by the owner", owner, http.MethodGet, "/snippet/edit/" + slug, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			if tt.method == http.MethodGet {
+				code, _, _ = tt.ts.get(t, tt.urlPath)
+			} else {
+				code, _, _ = tt.ts.postForm(t, tt.urlPath, tt.form)
+			}
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+		})
+	}
+
+	code, _, body := other.get(t, public)
+	if code != http.StatusOK || strings.Contains(body, "Defaced") {
+		t.Fatalf("after the refused writes: got status %d, defaced %t; want %d, untouched", code, strings.Contains(body, "Defaced"), http.StatusOK)
+	}
+	if strings.Contains(body, "/snippet/edit/"+slug) {
+		t.Error("another session is shown the edit link")
+	}
+
+	t.Run("Restore", func(t *testing.T) {
+		for _, s := range []string{slug, private} {
+			code, _, _ := owner.postForm(t, "/snippet/delete/"+s, url.Values{})
+			if code != http.StatusSeeOther {
+				t.Fatalf("owner deleting: got status %d; want %d", code, http.StatusSeeOther)
+			}
+		}
+		_, _, trash := other.get(t, "/snippet/trash")
+		if strings.Contains(trash, slug) {
+			t.Error("another session's trash lists the snippet")
+		}
+
+		if code, _, _ := other.postForm(t, "/snippet/restore/"+slug, url.Values{}); code != http.StatusForbidden {
+			t.Errorf("another session restoring: got status %d; want %d", code, http.StatusForbidden)
+		}
+		if code, _, _ := other.postForm(t, "/snippet/restore/"+private, url.Values{}); code != http.StatusNotFound {
+			t.Errorf("another session restoring a private snippet: got status %d; want %d", code, http.StatusNotFound)
+		}
+		if code, _, _ := owner.postForm(t, "/snippet/restore/"+slug, url.Values{}); code != http.StatusSeeOther {
+			t.Errorf("owner restoring: got status %d; want %d", code, http.StatusSeeOther)
+		}
+	})
+}
+
+func TestSnippetForkParent(t *testing.T) {
+	app := newTestApplication(t)
+	owner := newTestServer(t, app.routes())
+	other := owner.newSession(t)
+
+	fork := func(parent string) string {
+		form := snippetForm("A frog jumps", "A frog jumps into the pond")
+		form.Set("fork", path.Base(parent))
+		return owner.createSnippet(t, form)
+	}
+	form := snippetForm("An old silent pond", "An old silent pond...")
+	public := owner.createSnippet(t, form)
+	form.Set("visibility", models.VisibilityUnlisted)
+	unlisted := owner.createSnippet(t, form)
+	form.Set("visibility", models.VisibilityPrivate)
+	private := owner.createSnippet(t, form)
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		parent   string
+		wantLink bool
+	}{
+		{"Public parent", other, public, true},
+		{"Unlisted parent to another session", other, unlisted, false},
+		{"Unlisted parent to its owner", owner, unlisted, true},
+		{"Private parent to another session", other, private, false},
+		{"Private parent to its owner", owner, private, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := tt.ts.get(t, fork(tt.parent))
+			if code != http.StatusOK {
+				t.Fatalf("got status %d; want %d", code, http.StatusOK)
+			}
+			if got := strings.Contains(body, "href='"+tt.parent+"'"); got != tt.wantLink {
+				t.Errorf("links the parent: got %t; want %t", got, tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestSnippetList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	// slugs[i] is snippet #i+1, the listings go newest first
+	slugs := []string{}
+	for i := 1; i <= 5; i++ {
+		slugs = append(slugs, path.Base(ts.createSnippet(t, snippetForm(fmt.Sprintf("Pond %d", i), "An old silent pond..."))))
+	}
+	if code, _, _ := ts.postForm(t, "/snippet/delete/"+slugs[2], url.Values{}); code != http.StatusSeeOther {
+		t.Fatalf("deleting #3: got status %d; want %d", code, http.StatusSeeOther)
+	}
+
+	listed := regexp.MustCompile(`href='/s/([A-Za-z0-9]+)'`)
+	prev := regexp.MustCompile(`href='([^']*)'>&larr; Newer`)
+	next := regexp.MustCompile(`class='next' href='([^']*)'`)
+	link := func(re *regexp.Regexp, body string) string {
+		if m := re.FindStringSubmatch(body); m != nil {
+			return html.UnescapeString(m[1])
+		}
+		return ""
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		want     []string
+		wantPrev string
+		wantNext string
+	}{
+		{"First page", "/snippets?size=2", []string{slugs[4], slugs[3]}, "", "/snippets?before=4&size=2"},
+		{"Last page", "/snippets?before=4&size=2", []string{slugs[1], slugs[0]}, "/snippets?after=2&size=2", ""},
+		{"Short last page", "/snippets?before=2&size=2", []string{slugs[0]}, "/snippets?after=1&size=2", ""},
+		{"Back to the first page", "/snippets?after=2&size=2", []string{slugs[4], slugs[3]}, "", "/snippets?before=4&size=2"},
+		{"Deleted cursor, older", "/snippets?before=3&size=2", []string{slugs[1], slugs[0]}, "/snippets?after=2&size=2", ""},
+		{"Deleted cursor, newer", "/snippets?after=3&size=2", []string{slugs[4], slugs[3]}, "", "/snippets?before=4&size=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != http.StatusOK {
+				t.Fatalf("got status %d; want %d", code, http.StatusOK)
+			}
+			got := []string{}
+			for _, m := range listed.FindAllStringSubmatch(body, -1) {
+				got = append(got, m[1])
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got snippets %v; want %v", got, tt.want)
+			}
+			if got := link(prev, body); got != tt.wantPrev {
+				t.Errorf("got newer link %q; want %q", got, tt.wantPrev)
+			}
+			if got := link(next, body); got != tt.wantNext {
+				t.Errorf("got older link %q; want %q", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestSnippetDownload(t *testing.T) {
+	app := newTestApplication(t)
+	owner := newTestServer(t, app.routes())
+	other := owner.newSession(t)
+
+	form := snippetForm("Hello", "package main\n\nfunc main() {}\n")
+	form.Set("filename", "main.go")
+	form.Set("files[0].name", "go.mod")
+	form.Set("files[0].content", "module hello\n")
+	form.Set("files[1].name", "README.md")
+	form.Set("files[1].content", "# Hello\n")
+	files := path.Base(owner.createSnippet(t, form))
+
+	single := path.Base(owner.createSnippet(t, snippetForm("An old silent pond", "An old silent pond...")))
+
+	form = snippetForm("An old silent pond", "An old silent pond...")
+	form.Set("visibility", models.VisibilityPrivate)
+	private := path.Base(owner.createSnippet(t, form))
+
+	t.Run("Files", func(t *testing.T) {
+		tests := []struct {
+			name string
+			slug string
+			want map[string]string
+		}{
+			{"Named files", files, map[string]string{"main.go": "package main\n\nfunc main() {}\n", "go.mod": "module hello\n", "README.md": "# Hello\n"}},
+			{"Single file without a name", single, map[string]string{single + ".txt": "An old silent pond..."}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				code, header, body := other.get(t, "/snippet/download/"+tt.slug+".zip")
+				if code != http.StatusOK {
+					t.Fatalf("got status %d; want %d", code, http.StatusOK)
+				}
+				if got := header.Get("Content-Type"); got != "application/zip" {
+					t.Errorf("got Content-Type %q; want application/zip", got)
+				}
+				if got, want := header.Get("Content-Disposition"), `attachment; filename="`+tt.slug+`.zip"`; got != want {
+					t.Errorf("got Content-Disposition %q; want %q", got, want)
+				}
+
+				zr, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := map[string]string{}
+				for _, f := range zr.File {
+					rc, err := f.Open()
+					if err != nil {
+						t.Fatal(err)
+					}
+					b, err := io.ReadAll(rc)
+					rc.Close()
+					if err != nil {
+						t.Fatal(err)
+					}
+					got[f.Name] = string(b)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got files %q; want %q", got, tt.want)
+				}
+			})
+		}
+	})
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		urlPath  string
+		wantCode int
+	}{
+		{"Private to another session", other, "/snippet/download/" + private + ".zip", http.StatusNotFound},
+		{"Private to its owner", owner, "/snippet/download/" + private + ".zip", http.StatusOK},
+		{"Without .zip", other, "/snippet/download/" + files, http.StatusNotFound},
+		{"Unknown snippet", other, "/snippet/download/nosuchslug.zip", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := tt.ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -2,17 +2,44 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
 	"time"
 
 	"github.com/go-playground/form/v4"
+	"snippetbox.tushar.net/internal/constants"
+	"snippetbox.tushar.net/internal/models"
+	"snippetbox.tushar.net/internal/syntax"
 )
 
+// statusClientClosedRequest is nginx's status for a request whose client went
+// away before it was answered. Nobody sees the response, only the logs do.
+const statusClientClosedRequest = 499
+
+// serverError answers with a 500 and logs the stack trace. Queries and blob
+// storage calls cut short by the timeout are a 503 instead, the backend is
+// struggling rather than broken, and ones canceled by the client leaving are
+// a 499.
 func (app *application) serverError(w http.ResponseWriter, err error) {
 
+	switch {
+	case errors.Is(err, constants.ErrCanceled):
+		app.infoLog.Print(err)
+		w.WriteHeader(statusClientClosedRequest)
+		return
+	case errors.Is(err, constants.ErrTimeout):
+		app.errorLog.Print(err)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.errorLog.Print(trace) // printing the trace and returns 5xx
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -63,9 +90,19 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 // Common dynamic data  data which is/can be used in all pages
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	// fmt.Println(r.URL)
+	style := app.sessionManager.GetString(r.Context(), "style")
+	if style == "" {
+		style = syntax.DefaultStyle
+	}
 	return &templateData{
 		CurrentYear: time.Now().Year(),
 		Flash:       app.sessionManager.PopString(r.Context(), "flash"),
+		Style:       style,
+
+		ExpiryOptions: app.expiryOptions,
+
+		MaxAttachments:    maxAttachments,
+		MaxAttachmentSize: app.maxAttachmentSize,
 	}
 }
 
@@ -95,3 +132,95 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	}
 	return nil
 }
+
+// queryInt reads a non-negative integer from the query string, returning def
+// when the parameter isn't there.
+func queryInt(query url.Values, key string, def int) (int, error) {
+	if !query.Has(key) {
+		return def, nil
+	}
+	n, err := strconv.Atoi(query.Get(key))
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("negative %s", key)
+	}
+	return n, nil
+}
+
+// locked reports whether snippet has a password that hasn't been entered in
+// this session yet.
+func (app *application) locked(r *http.Request, snippet *models.Snippet) bool {
+	return snippet.Protected && !app.sessionManager.GetBool(r.Context(), unlockKey(snippet.ID))
+}
+
+// hidden reports whether snippet is private to another session. Handlers treat
+// those as missing rather than forbidden, so their ids don't give them away.
+func (app *application) hidden(r *http.Request, snippet *models.Snippet) bool {
+	if snippet.Visibility != models.VisibilityPrivate {
+		return false
+	}
+	owner := app.sessionManager.GetString(r.Context(), "owner")
+	return !snippet.OwnedBy(owner)
+}
+
+// owns reports whether this session created snippet, which only that session
+// may edit or delete. Snippets from before owners were recorded have none, and
+// can't be changed any more.
+func (app *application) owns(r *http.Request, snippet *models.Snippet) bool {
+	owner := app.sessionManager.GetString(r.Context(), "owner")
+	return snippet.OwnedBy(owner)
+}
+
+// ownerToken returns the random token identifying this session as the owner
+// of the snippets it creates, making one up on first use. There are no user
+// accounts, so losing the session means losing access to private snippets.
+func (app *application) ownerToken(r *http.Request) (string, error) {
+	owner := app.sessionManager.GetString(r.Context(), "owner")
+	if owner != "" {
+		return owner, nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	owner = hex.EncodeToString(b)
+	app.sessionManager.Put(r.Context(), "owner", owner)
+	return owner, nil
+}
+
+// unlockKey is the session key remembering that a snippet was unlocked.
+func unlockKey(id int) string {
+	return fmt.Sprintf("unlocked:%d", id)
+}
+
+// clientIP returns the address the request came from, without the port. The
+// app isn't set up to sit behind a proxy, so forwarding headers are ignored.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parent returns the snippet a fork was made from, or nil for an original and
+// for a parent that's gone or isn't public. A private or unlisted parent is
+// only linked for the session that owns it, the fork mustn't give it away.
+func (app *application) parent(r *http.Request, snippet *models.Snippet) (*models.Snippet, error) {
+	if snippet.ForkedFrom == 0 {
+		return nil, nil
+	}
+	parent, err := app.snippetModel.Get(r.Context(), snippet.ForkedFrom)
+	if err != nil {
+		if errors.Is(err, constants.ErrNoRecord) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if parent.BurnAfterReading || (parent.Visibility != models.VisibilityPublic && !app.owns(r, parent)) {
+		return nil, nil
+	}
+	return parent, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// attemptLimiter counts failures per key (a snippet, an IP address) in fixed
+// windows and refuses further attempts once max failures have been counted
+// in the current window. Attempts count as failures from the start, see
+// Reserve.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	failures map[string]*failures
+}
+
+type failures struct {
+	count int
+	reset time.Time
+}
+
+// stale windows are dropped once this many keys are being tracked, so
+// guessing from many addresses can't grow the map without bound
+const limiterSweepSize = 10000
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		failures: make(map[string]*failures),
+	}
+}
+
+// Reserve counts an attempt for key before it's made, so parallel attempts
+// can't all get in before the first failure is recorded. It reports whether
+// the attempt may go ahead, and if not, how long until the window resets; a
+// refused attempt isn't counted. Attempts that turn out not to be failures
+// are given back with Refund.
+func (l *attemptLimiter) Reserve(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if len(l.failures) >= limiterSweepSize {
+		for k, f := range l.failures {
+			if !f.reset.After(now) {
+				delete(l.failures, k)
+			}
+		}
+	}
+
+	f, ok := l.failures[key]
+	if !ok || !f.reset.After(now) {
+		f = &failures{reset: now.Add(l.window)}
+		l.failures[key] = f
+	}
+	if f.count >= l.max {
+		return false, f.reset.Sub(now)
+	}
+	f.count++
+	return true, 0
+}
+
+// Refund gives back an attempt Reserve counted for key, for one that
+// succeeded or never got to check anything.
+func (l *attemptLimiter) Refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if f, ok := l.failures[key]; ok && f.count > 0 {
+		f.count--
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	l := newAttemptLimiter(3, time.Hour)
+
+	for i := range 3 {
+		if ok, _ := l.Reserve("a"); !ok {
+			t.Fatalf("attempt %d refused; want the first 3 allowed", i+1)
+		}
+	}
+	ok, wait := l.Reserve("a")
+	if ok {
+		t.Fatal("attempt 4 allowed; want it refused")
+	}
+	if wait <= 0 || wait > time.Hour {
+		t.Errorf("got a wait of %s; want up to an hour", wait)
+	}
+
+	// keys are counted apart
+	if ok, _ := l.Reserve("b"); !ok {
+		t.Error("another key was refused")
+	}
+
+	// a refunded attempt can be made again, but only one
+	l.Refund("a")
+	if ok, _ := l.Reserve("a"); !ok {
+		t.Error("refused after a refund")
+	}
+	if ok, _ := l.Reserve("a"); ok {
+		t.Error("allowed past the limit after one refund")
+	}
+}
+
+func TestAttemptLimiterWindow(t *testing.T) {
+	l := newAttemptLimiter(1, 20*time.Millisecond)
+
+	if ok, _ := l.Reserve("a"); !ok {
+		t.Fatal("first attempt refused")
+	}
+	if ok, _ := l.Reserve("a"); ok {
+		t.Fatal("second attempt allowed within the window")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if ok, _ := l.Reserve("a"); !ok {
+		t.Error("refused in a new window")
+	}
+}
+
+// createProtected creates a public snippet with password and returns its url.
+func (ts *testServer) createProtected(t *testing.T, password string) string {
+	t.Helper()
+
+	form := snippetForm("Locked pond", "An old silent pond...")
+	form.Set("password", password)
+	return ts.createSnippet(t, form)
+}
+
+// unlock posts password for the snippet at snippetURL and returns the status.
+func (ts *testServer) unlock(t *testing.T, snippetURL, password string) (int, http.Header) {
+	t.Helper()
+
+	code, header, _ := ts.postForm(t, "/snippet/unlock/"+path.Base(snippetURL), url.Values{"password": {password}})
+	return code, header
+}
+
+func TestSnippetUnlockLimits(t *testing.T) {
+	const password = "correct horse"
+
+	t.Run("Per IP", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.ipUnlockLimiter = newAttemptLimiter(2, time.Hour)
+		ts := newTestServer(t, app.routes())
+		first, second := ts.createProtected(t, password), ts.createProtected(t, password)
+
+		for range 2 {
+			if code, _ := ts.newSession(t).unlock(t, first, "wrong password"); code != http.StatusUnprocessableEntity {
+				t.Fatalf("wrong password: got status %d; want %d", code, http.StatusUnprocessableEntity)
+			}
+		}
+		// the limit is on the address, whichever snippet and session
+		code, header := ts.newSession(t).unlock(t, second, password)
+		if code != http.StatusTooManyRequests {
+			t.Fatalf("past the limit: got status %d; want %d", code, http.StatusTooManyRequests)
+		}
+		retry, err := strconv.Atoi(header.Get("Retry-After"))
+		if err != nil || retry <= 0 || retry > int(time.Hour.Seconds())+1 {
+			t.Errorf("got Retry-After %q; want seconds until the window resets", header.Get("Retry-After"))
+		}
+	})
+
+	t.Run("Per snippet", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.snippetUnlockLimiter = newAttemptLimiter(2, time.Hour)
+		ts := newTestServer(t, app.routes())
+		first, second := ts.createProtected(t, password), ts.createProtected(t, password)
+
+		for range 2 {
+			ts.unlock(t, first, "wrong password")
+		}
+		if code, header := ts.unlock(t, first, password); code != http.StatusTooManyRequests || header.Get("Retry-After") == "" {
+			t.Fatalf("past the limit: got status %d with Retry-After %q; want %d with one", code, header.Get("Retry-After"), http.StatusTooManyRequests)
+		}
+		if code, _ := ts.unlock(t, second, password); code != http.StatusSeeOther {
+			t.Errorf("another snippet: got status %d; want %d", code, http.StatusSeeOther)
+		}
+	})
+
+	t.Run("Refund after the right password", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.ipUnlockLimiter = newAttemptLimiter(2, time.Hour)
+		ts := newTestServer(t, app.routes())
+		snippet := ts.createProtected(t, password)
+
+		ts.newSession(t).unlock(t, snippet, "wrong password")
+		for range 3 {
+			if code, _ := ts.newSession(t).unlock(t, snippet, password); code != http.StatusSeeOther {
+				t.Fatalf("right password: got status %d; want %d", code, http.StatusSeeOther)
+			}
+		}
+		if code, _ := ts.newSession(t).unlock(t, snippet, "wrong password"); code != http.StatusUnprocessableEntity {
+			t.Errorf("second wrong password: got status %d; want %d", code, http.StatusUnprocessableEntity)
+		}
+		if code, _ := ts.newSession(t).unlock(t, snippet, "wrong password"); code != http.StatusTooManyRequests {
+			t.Errorf("third wrong password: got status %d; want %d", code, http.StatusTooManyRequests)
+		}
+	})
+}
+
+func TestSnippetUnlock(t *testing.T) {
+	const password = "correct horse"
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	snippet := ts.createProtected(t, password)
+
+	guest := ts.newSession(t)
+	for _, wrong := range []string{"", "wrong password", password + " "} {
+		if code, _ := guest.unlock(t, snippet, wrong); code != http.StatusUnprocessableEntity {
+			t.Errorf("password %q: got status %d; want %d", wrong, code, http.StatusUnprocessableEntity)
+		}
+	}
+	_, _, body := guest.get(t, snippet)
+	if strings.Contains(body, "An old silent pond...") {
+		t.Fatal("wrong passwords unlocked the snippet")
+	}
+
+	if code, header := guest.unlock(t, snippet, password); code != http.StatusSeeOther || header.Get("Location") != snippet {
+		t.Fatalf("right password: got status %d to %q; want %d to %q", code, header.Get("Location"), http.StatusSeeOther, snippet)
+	}
+	_, _, body = guest.get(t, snippet)
+	if !strings.Contains(body, "An old silent pond...") {
+		t.Error("the right password didn't unlock the snippet")
+	}
+}
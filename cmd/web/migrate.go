@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"snippetbox.tushar.net/internal/migrations"
+)
+
+// runMigrate handles `web [flags] migrate up|down|status`.
+//
+//	up     - apply every pending migration
+//	down   - roll back the most recently applied migration
+//	status - list migrations and whether they have been applied
+func runMigrate(w io.Writer, driver string, db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: web [flags] migrate up|down|status")
+	}
+	m := &migrations.Migrator{DB: db, Dialect: driver}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, mig := range applied {
+			fmt.Fprintf(w, "applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "schema is up to date")
+		}
+	case "down":
+		mig, err := m.Down()
+		if err != nil {
+			return err
+		}
+		if mig == nil {
+			fmt.Fprintln(w, "no migrations to roll back")
+			return nil
+		}
+		fmt.Fprintf(w, "rolled back %04d_%s\n", mig.Version, mig.Name)
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt
+			}
+			fmt.Fprintf(w, "%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", args[0])
+	}
+	return nil
+}
+
+// checkSchema returns an error when the database is missing migrations, so the
+// server doesn't start up against tables it would fail to query.
+func checkSchema(driver string, db *sql.DB) error {
+	m := &migrations.Migrator{DB: db, Dialect: driver}
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is %d migration(s) behind, run `web migrate up`", len(pending))
+	}
+	return nil
+}
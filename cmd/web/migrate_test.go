@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckSchema(t *testing.T) {
+	driver, dsn := parseDSN("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrate := func(command string) {
+		t.Helper()
+		var out bytes.Buffer
+		if err := runMigrate(&out, driver, db, []string{command}); err != nil {
+			t.Fatalf("migrate %s: %v", command, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr string
+	}{
+		{"Empty database", "", "behind"},
+		{"Up to date", "up", ""},
+		{"One migration behind", "down", "1 migration(s) behind"},
+		{"Up to date again", "up", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.command != "" {
+				migrate(tt.command)
+			}
+			err := checkSchema(driver, db)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("got %v; want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("got %v; want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
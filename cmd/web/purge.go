@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"snippetbox.tushar.net/internal/models"
+)
+
+// runPurge handles `web [flags] purge`, which permanently removes snippets that
+// have been in the trash for longer than grace. The reaper does the same while
+// the app runs; this is for purging by hand, or from cron with -reap-interval=0.
+func runPurge(w io.Writer, snippets models.SnippetStore, grace time.Duration) error {
+	n, err := snippets.PurgeDeleted(context.Background(), grace)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "purged %d snippet(s) deleted more than %s ago\n", n, humanDuration(grace))
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// startReaper runs a background goroutine that permanently removes expired
+// snippets every interval, at most batch rows per statement so a big backlog
+// doesn't hold long locks, and the snippets that have been in the trash for
+// longer than the grace period. The returned func stops it and waits for a sweep
+// that's in progress to finish.
+func (app *application) startReaper(interval time.Duration, batch int) (stop func()) {
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				n, err := app.reap(batch, done)
+				if err != nil {
+					app.errorLog.Print(err)
+				}
+				if n > 0 {
+					app.infoLog.Printf("reaper: removed %d expired snippet(s)", n)
+				}
+				n, err = app.reapTrash()
+				if err != nil {
+					app.errorLog.Print(err)
+				}
+				if n > 0 {
+					app.infoLog.Printf("reaper: removed %d snippet(s) from the trash", n)
+				}
+				// also picks up what a purge of the trash left behind
+				n, err = app.reapBlobs(batch, done)
+				if err != nil {
+					app.errorLog.Print(err)
+				}
+				if n > 0 {
+					app.infoLog.Printf("reaper: removed %d orphaned blob(s)", n)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// reap deletes expired snippets batch by batch until there are none left or
+// done is closed, and returns how many it removed. A panic is turned into an
+// error so the reaper keeps running, like recoverPanic does for requests.
+// Statements aren't canceled on shutdown, done is only checked between them.
+func (app *application) reap(batch int, done <-chan struct{}) (total int, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("reaper: %s\n%s", r, debug.Stack())
+		}
+	}()
+
+	ctx := context.Background()
+	for {
+		n, err := app.snippetModel.PurgeExpired(ctx, batch)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("reaper: %w", err)
+		}
+		if n < batch {
+			return total, nil
+		}
+		select {
+		case <-done:
+			return total, nil
+		default:
+		}
+	}
+}
+
+// reapTrash removes the snippets deleted longer than trashGrace ago, what the
+// purge command does, and returns how many it removed.
+func (app *application) reapTrash() (n int, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("reaper: %s\n%s", r, debug.Stack())
+		}
+	}()
+
+	n, err = app.snippetModel.PurgeDeleted(context.Background(), app.trashGrace)
+	if err != nil {
+		return n, fmt.Errorf("reaper: %w", err)
+	}
+	return n, nil
+}
+
+// reapBlobs deletes the blobs of attachments and offloaded content whose
+// snippet has been removed, then their rows, batch by batch. A blob that can't be
+// deleted keeps its attachment, so it's tried again on the next sweep.
+func (app *application) reapBlobs(batch int, done <-chan struct{}) (total int, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("reaper: %s\n%s", r, debug.Stack())
+		}
+	}()
+
+	ctx := context.Background()
+	for {
+		keys, err := app.snippetModel.OrphanedBlobs(ctx, batch)
+		if err != nil {
+			return total, fmt.Errorf("reaper: %w", err)
+		}
+
+		deleted := []string{}
+		for _, key := range keys {
+			if err := app.blobs.Delete(ctx, key); err != nil {
+				app.errorLog.Printf("reaper: %s", err)
+				continue
+			}
+			deleted = append(deleted, key)
+		}
+		if err := app.snippetModel.DeleteBlobs(ctx, deleted); err != nil {
+			return total, fmt.Errorf("reaper: %w", err)
+		}
+		total += len(deleted)
+
+		// with failures the same keys would come back straight away
+		if len(keys) < batch || len(deleted) < len(keys) {
+			return total, nil
+		}
+		select {
+		case <-done:
+			return total, nil
+		default:
+		}
+	}
+}
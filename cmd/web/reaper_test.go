@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.tushar.net/internal/models"
+)
+
+func TestReapTrash(t *testing.T) {
+	app := newTestApplication(t)
+	ctx := context.Background()
+
+	id, _, err := app.snippetModel.Insert(ctx, models.NewSnippet{Title: "Deleted", Content: "x", Visibility: models.VisibilityPublic, Owner: "owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.snippetModel.Delete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	inTrash := func() int {
+		trash, err := app.snippetModel.Trash(ctx, time.Hour, "owner")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(trash)
+	}
+
+	app.trashGrace = time.Hour
+	if n, err := app.reapTrash(); err != nil || n != 0 {
+		t.Fatalf("within the grace period: got %d, %v; want 0, nil", n, err)
+	}
+	if inTrash() != 1 {
+		t.Fatal("the snippet was removed from the trash within the grace period")
+	}
+
+	app.trashGrace = 0
+	if n, err := app.reapTrash(); err != nil || n != 1 {
+		t.Fatalf("past the grace period: got %d, %v; want 1, nil", n, err)
+	}
+	if inTrash() != 0 {
+		t.Error("the snippet is still in the trash past the grace period")
+	}
+}
+
+// batchStore records the size of every batch PurgeExpired removes, and calls
+// after, if set, once each has.
+type batchStore struct {
+	models.SnippetStore
+	batches []int
+	after   func()
+}
+
+func (s *batchStore) PurgeExpired(ctx context.Context, limit int) (int, error) {
+	n, err := s.SnippetStore.PurgeExpired(ctx, limit)
+	s.batches = append(s.batches, n)
+	if s.after != nil {
+		s.after()
+	}
+	return n, err
+}
+
+// panicStore panics on PurgeExpired, as a bug in a store would.
+type panicStore struct {
+	models.SnippetStore
+}
+
+func (panicStore) PurgeExpired(ctx context.Context, limit int) (int, error) {
+	panic("purge went wrong")
+}
+
+func TestReap(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		expired     int
+		stopAfter   bool // close done after the first batch
+		wantTotal   int
+		wantBatches []int
+	}{
+		{"Nothing expired", 0, false, 0, []int{0}},
+		{"Last batch short", 7, false, 7, []int{3, 3, 1}},
+		{"Last batch full", 6, false, 6, []int{3, 3, 0}},
+		{"Done closed", 7, true, 3, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			for i := 0; i < tt.expired; i++ {
+				_, _, err := app.snippetModel.Insert(ctx, models.NewSnippet{Title: "Expired", Content: "x", Expires: time.Now().Add(-time.Hour), Visibility: models.VisibilityPublic})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			live, _, err := app.snippetModel.Insert(ctx, models.NewSnippet{Title: "Live", Content: "x", Visibility: models.VisibilityPublic})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			done := make(chan struct{})
+			store := &batchStore{SnippetStore: app.snippetModel}
+			if tt.stopAfter {
+				store.after = func() {
+					if len(store.batches) == 1 {
+						close(done)
+					}
+				}
+			}
+			app.snippetModel = store
+
+			total, err := app.reap(3, done)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.wantTotal || !slices.Equal(store.batches, tt.wantBatches) {
+				t.Errorf("got %d in batches %v; want %d in batches %v", total, store.batches, tt.wantTotal, tt.wantBatches)
+			}
+			if _, err := app.snippetModel.Get(ctx, live); err != nil {
+				t.Errorf("live snippet: got %v; want it left alone", err)
+			}
+		})
+	}
+
+	t.Run("Panic", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.snippetModel = panicStore{app.snippetModel}
+		if _, err := app.reap(3, make(chan struct{})); err == nil || !strings.Contains(err.Error(), "purge went wrong") {
+			t.Errorf("got %v; want the panic as an error", err)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"snippetbox.tushar.net/internal/models"
+)
+
+// readPrimaryUntilKey is the session key holding when, in unix nanoseconds,
+// the session stops reading from the primary after its last write.
+const readPrimaryUntilKey = "readPrimaryUntil"
+
+// openReplicas opens the read replicas, which speak the same dialect as the
+// primary. A replica that can't be reached doesn't stop the app, it's left
+// out of rotation until a health check gets through.
+func openReplicas(driver string, dsns []string, newStore func(*sql.DB) models.SnippetStore) ([]*models.Replica, error) {
+
+	replicas := []*models.Replica{}
+	for i, dsn := range dsns {
+		replicaDriver, source := parseDSN(dsn)
+		if replicaDriver != driver {
+			return nil, fmt.Errorf("replica %d is %s, but the primary is %s", i+1, replicaDriver, driver)
+		}
+		db, err := sql.Open(driver, source)
+		if err != nil {
+			return nil, err
+		}
+		// the dsn has the password, the logs only get its position
+		replicas = append(replicas, &models.Replica{Name: fmt.Sprintf("replica %d", i+1), Store: newStore(db), DB: db})
+	}
+	return replicas, nil
+}
+
+// checkReplicas pings every replica, logging the ones that went down or
+// came back.
+func (app *application) checkReplicas(replicas []*models.Replica, timeout time.Duration) {
+
+	for _, r := range replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		changed, err := r.Check(ctx)
+		cancel()
+		switch {
+		case changed && err != nil:
+			app.errorLog.Printf("%s is down, it gets no reads until it answers again: %s", r.Name, err)
+		case changed:
+			app.infoLog.Printf("%s is back up", r.Name)
+		}
+	}
+}
+
+// startReplicaChecks runs checkReplicas every interval until the returned
+// func is called, like the reaper.
+func (app *application) startReplicaChecks(replicas []*models.Replica, interval time.Duration) (stop func()) {
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				app.checkReplicas(replicas, interval)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// wrote makes the session read from the primary for a while, so that the
+// redirect after a create or edit shows it even if the replicas lag behind.
+func (app *application) wrote(r *http.Request) {
+	if app.replicaLag > 0 {
+		app.sessionManager.Put(r.Context(), readPrimaryUntilKey, time.Now().Add(app.replicaLag).UnixNano())
+	}
+}
+
+// readYourWrites sends the reads of a session that wrote recently to the
+// primary, see wrote. It needs the session loaded.
+func (app *application) readYourWrites(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		until := app.sessionManager.GetInt64(r.Context(), readPrimaryUntilKey)
+		if until > time.Now().UnixNano() {
+			r = r.WithContext(models.ReadPrimary(r.Context()))
+		} else if until != 0 {
+			app.sessionManager.Remove(r.Context(), readPrimaryUntilKey)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"snippetbox.tushar.net/internal/models"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	app := newTestApplication(t)
+	// a replica that never catches up, it only ever has what's put in it here
+	replica := models.NewMemorySnippetModel()
+	app.snippetModel = &models.ReplicaStore{
+		Primary:  app.snippetModel,
+		Replicas: []*models.Replica{{Name: "replica 1", Store: replica}},
+	}
+	app.replicaLag = time.Second
+
+	writer := newTestServer(t, app.routes())
+	other := writer.newSession(t)
+	urlPath := writer.createSnippet(t, snippetForm("An old silent pond", "An old silent pond..."))
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		wait     time.Duration
+		wantCode int
+	}{
+		{"Writer within the lag", writer, 0, http.StatusOK},
+		{"Another session", other, 0, http.StatusNotFound},
+		{"Writer after the lag", writer, app.replicaLag, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(tt.wait)
+			code, _, _ := tt.ts.get(t, urlPath)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -25,13 +25,37 @@ func (app *application) routes() http.Handler {
 	// Create a new middleware chain containing the middleware specific to our
 	// dynamic application routes. For now, this chain will only contain the
 	// LoadAndSave session middleware but we'll add more to it later.
-	dynamic := alice.New(app.sessionManager.LoadAndSave)
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.readYourWrites)
 
 	// mux := http.NewServeMux()					                              // This is a middleware handler which keeps a map of {path : handler} and does the re-direction
-	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))                             // exact match to "/{$}" path
-	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))      // fixed path and not a subtree
-	router.Handler(http.MethodGet, "/snippet/create", dynamic.ThenFunc(app.snippetCreate))      // get create snippet form
-	router.Handler(http.MethodPost, "/snippet/create", dynamic.ThenFunc(app.snippetCreatePost)) // save snippet
+	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))                                       // exact match to "/{$}" path
+	router.Handler(http.MethodGet, "/snippets", dynamic.ThenFunc(app.snippetList))                        // paginated, ?before=&after=&size=
+	router.Handler(http.MethodGet, "/tag/:name", dynamic.ThenFunc(app.snippetList))                       // snippets with a tag, same paging as /snippets
+	router.Handler(http.MethodGet, "/s/:slug", dynamic.ThenFunc(app.snippetView))                         // fixed path and not a subtree
+	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetViewRedirect))        // old numeric url, public snippets only
+	router.Handler(http.MethodGet, "/snippet/create", dynamic.ThenFunc(app.snippetCreate))                // get create snippet form
+	router.Handler(http.MethodPost, "/snippet/create", dynamic.ThenFunc(app.snippetCreatePost))           // save snippet
+	router.Handler(http.MethodGet, "/s/:slug/rev/:n", dynamic.ThenFunc(app.snippetRevisionView))          // older revision of a snippet
+	router.Handler(http.MethodGet, "/s/:slug/attachments/:id", dynamic.ThenFunc(app.snippetAttachment))   // uploaded file
+	router.Handler(http.MethodGet, "/snippet/view/:id/rev/:n", dynamic.ThenFunc(app.snippetViewRedirect)) // old numeric url, public snippets only
+	router.Handler(http.MethodGet, "/snippet/edit/:slug", dynamic.ThenFunc(app.snippetEdit))              // get edit form
+	router.Handler(http.MethodPost, "/snippet/edit/:slug", dynamic.ThenFunc(app.snippetEditPost))         // save new revision
+	router.Handler(http.MethodGet, "/snippet/diff/:slug", dynamic.ThenFunc(app.snippetDiff))              // ?from=&to=&with=&view=&format=
+	router.Handler(http.MethodGet, "/snippet/fork/:slug", dynamic.ThenFunc(app.snippetFork))              // create form filled in with a copy
+	router.Handler(http.MethodGet, "/snippet/download/:file", dynamic.ThenFunc(app.snippetDownload))      // every file as :slug.zip
+
+	router.Handler(http.MethodGet, "/search", dynamic.ThenFunc(app.search))                             // ?q=
+	router.Handler(http.MethodPost, "/style", dynamic.ThenFunc(app.stylePost))                          // remember the highlighting style
+	router.Handler(http.MethodGet, "/highlight/:style", http.HandlerFunc(app.highlightCSS))             // e.g. /highlight/monokai.css
+	router.Handler(http.MethodPost, "/snippet/delete/:slug", dynamic.ThenFunc(app.snippetDeletePost))   // move to trash
+	router.Handler(http.MethodGet, "/snippet/trash", dynamic.ThenFunc(app.snippetTrash))                // deleted, still restorable
+	router.Handler(http.MethodPost, "/snippet/restore/:slug", dynamic.ThenFunc(app.snippetRestorePost)) // take out of trash
+	router.Handler(http.MethodPost, "/snippet/unlock/:slug", dynamic.ThenFunc(app.snippetUnlockPost))   // password of a protected snippet
+
+	// only with -debug, they're open to anyone
+	if app.debug {
+		router.Handler(http.MethodGet, "/debug/cache", http.HandlerFunc(app.cacheStats)) // cache hit and miss counters
+	}
 
 	// composable middleware and cleanr/easier to understand using alice pkg
 	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
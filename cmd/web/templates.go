@@ -1,11 +1,19 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"snippetbox.tushar.net/internal/diff"
 	"snippetbox.tushar.net/internal/models"
+	"snippetbox.tushar.net/internal/syntax"
 )
 
 // Define a templateData type to act as the holding structure for
@@ -15,9 +23,54 @@ import (
 type templateData struct {
 	Snippet     *models.Snippet
 	Snippets    []*models.Snippet
+	Revision    *models.Revision
+	Revisions   []*models.Revision
 	CurrentYear int
 	Form        any
 	Flash       string
+	Diff        *diffData
+	TrashGrace  time.Duration
+	Page        *pageLinks
+	Search      *searchData
+	Tag         string            // tag the listing is filtered by
+	Style       string            // highlighting style picked by the user
+	Link        string            // url of a new burn after reading snippet, a path without -base-url
+	Source      *models.Snippet   // snippet being forked on the create form
+	Parent      *models.Snippet   // snippet the viewed one was forked from, if it can be shown
+	Forks       []*models.Snippet // listed forks of the viewed snippet
+	Owned       bool              // the session created the viewed snippet, and may edit or delete it
+
+	ExpiryOptions []expiryOption // choices on the create form
+
+	MaxAttachments    int   // how many files can be attached to a new snippet
+	MaxAttachmentSize int64 // largest attachment in bytes
+}
+
+// searchData is the query behind search.tmpl and the words to highlight.
+type searchData struct {
+	Query string
+	Terms []string
+}
+
+// pageLinks are the previous/next links of a paginated listing, empty when
+// there's no page in that direction.
+type pageLinks struct {
+	Prev string
+	Next string
+}
+
+// diffData holds what diff.tmpl needs to show the changes between two revisions.
+type diffData struct {
+	From        *models.Revision
+	To          *models.Revision
+	FromSlug    string
+	ToSlug      string
+	View        string // "unified" or "split"
+	Hunks       []diff.Hunk
+	TooLarge    bool // the revisions are past diff.MaxLines or diff.MaxEdits
+	UnifiedURL  string
+	SplitURL    string
+	DownloadURL string
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -76,12 +129,126 @@ func newTemplateCache() (map[string]*template.Template, error) {
 // Create a humanDate function which returns a nicely formatted string
 // representation of a time.Time object.
 func humanDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// humanDuration formats d in the largest whole unit that fits, like "7 days"
+// or "30 minutes".
+func humanDuration(d time.Duration) string {
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, u := range units {
+		if d >= u.size && d%u.size == 0 {
+			n := int(d / u.size)
+			if n == 1 {
+				return fmt.Sprintf("1 %s", u.name)
+			}
+			return fmt.Sprintf("%d %ss", n, u.name)
+		}
+	}
+	return d.String()
+}
+
+// humanSize formats a number of bytes with a binary unit, like "1.5 MiB".
+func humanSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size, unit := float64(n)/1024, "KiB"
+	for _, next := range []string{"MiB", "GiB"} {
+		if size < 1024 {
+			break
+		}
+		size, unit = size/1024, next
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", size), ".0") + " " + unit
+}
+
+// highlight escapes text and wraps every occurrence of the search terms in
+// <mark>. Matching is case-insensitive and on whole words, like the fulltext
+// search that found the snippet.
+func highlight(text string, terms []string) template.HTML {
+	var b strings.Builder
+	last := 0
+	for _, m := range termMatches(text, terms) {
+		b.WriteString(template.HTMLEscapeString(text[last:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		last = m[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+	return template.HTML(b.String())
+}
+
+// excerpt cuts a window of about width runes out of text around the first
+// search term match and highlights it. Without a match it shows the start.
+func excerpt(text string, terms []string, width int) template.HTML {
+	runes := []rune(text)
+	start := 0
+	if matches := termMatches(text, terms); len(matches) > 0 {
+		// put the match a third of the way in, counting in runes not bytes
+		start = max(utf8.RuneCountInString(text[:matches[0][0]])-width/3, 0)
+	}
+	end := min(start+width, len(runes))
+
+	s := string(runes[start:end])
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(runes) {
+		s += "…"
+	}
+	return highlight(s, terms)
+}
+
+// termMatches returns the byte offsets of whole-word, case-insensitive
+// matches of terms in text.
+func termMatches(text string, terms []string) [][]int {
+	if len(terms) == 0 {
+		return nil
+	}
+	// longest first, so a term that's a prefix of another doesn't win the
+	// alternation and then get thrown out by the word boundary check
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	matches := [][]int{}
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:m[0]])
+		after, _ := utf8.DecodeRuneInString(text[m[1]:])
+		if !isWord(before) && !isWord(after) {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
 // Initialize a template.FuncMap object and store it in a global variable. This is
 // essentially a string-keyed map which acts as a lookup between the names of our
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"humanDuration": humanDuration,
+	"humanSize":     humanSize,
+	"highlight":     highlight,
+	"excerpt":       excerpt,
+	"syntax":        syntax.Code,
+	"languageLabel": syntax.Label,
+	"languages":     func() []syntax.Language { return syntax.Languages },
+	"styles":        func() []string { return syntax.Styles },
 }
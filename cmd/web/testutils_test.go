@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-playground/form/v4"
+	"snippetbox.tushar.net/internal/blobs"
+	"snippetbox.tushar.net/internal/models"
+)
+
+// The templates and static files are read from ./ui, relative to the root of
+// the repository, like when the app is run with go run ./cmd/web.
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestApplication returns an application on MemorySnippetModel, so the
+// handlers can be tested without a database.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiryOptions, err := parseExpiryOptions("10m,1d,never", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionManager := scs.New()
+	sessionManager.Lifetime = 12 * time.Hour
+
+	blobStore, err := blobs.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &application{
+		errorLog:       log.New(io.Discard, "", 0),
+		infoLog:        log.New(io.Discard, "", 0),
+		snippetModel:   models.NewMemorySnippetModel(),
+		templateCache:  templateCache,
+		formDecoder:    form.NewDecoder(),
+		sessionManager: sessionManager,
+		trashGrace:     time.Hour,
+		expiryOptions:  expiryOptions,
+
+		blobs:             blobStore,
+		maxAttachmentSize: 1 << 20,
+
+		ipUnlockLimiter:      newAttemptLimiter(maxUnlockFailuresPerIP, unlockWindow),
+		snippetUnlockLimiter: newAttemptLimiter(maxUnlockFailuresPerSnippet, unlockWindow),
+	}
+}
+
+// testServer is one session against the app: it keeps its cookies between
+// requests and doesn't follow redirects, so tests can check where they point.
+type testServer struct {
+	*httptest.Server
+	client *http.Client
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	t.Helper()
+
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return (&testServer{Server: ts}).newSession(t)
+}
+
+// newSession returns the same server seen from a new session, without the
+// cookies of ts.
+func (ts *testServer) newSession(t *testing.T) *testServer {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &testServer{Server: ts.Server, client: client}
+}
+
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	t.Helper()
+
+	rs, err := ts.client.Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, rs)
+}
+
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	t.Helper()
+
+	rs, err := ts.client.PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, rs)
+}
+
+// upload is a file sent in a multipart form.
+type upload struct {
+	field, name string
+	content     []byte
+}
+
+// postMultipart posts form along with uploads, the way the create form does
+// when it has attachments.
+func (ts *testServer) postMultipart(t *testing.T, urlPath string, form url.Values, uploads []upload) (int, http.Header, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for key, values := range form {
+		for _, v := range values {
+			if err := mw.WriteField(key, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, u := range uploads {
+		fw, err := mw.CreateFormFile(u.field, u.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(u.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.client.Post(ts.URL+urlPath, mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readResponse(t, rs)
+}
+
+func readResponse(t *testing.T, rs *http.Response) (int, http.Header, string) {
+	t.Helper()
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(body))
+}
@@ -0,0 +1,55 @@
+// Package blobs keeps the bytes of uploaded files outside the database. The
+// models only store a key for each blob.
+package blobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound is returned by Get for a key that has no blob.
+var ErrNotFound = errors.New("blobs: no such blob")
+
+// Store is where blobs are kept. Keys come from NewKey. Canceling ctx
+// abandons the operation; for Get that includes reading the blob.
+type Store interface {
+	// Put stores the size bytes read from r under key. Object stores need
+	// the size up front to avoid buffering the whole blob.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the blob stored under key, the caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key, one that's already gone isn't an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewKey returns a random key for a new blob, 32 lowercase hex characters.
+func NewKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validKey reports whether key looks like one from NewKey, so that a key can
+// never reach outside of a store.
+func validKey(key string) bool {
+	if len(key) != 32 {
+		return false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func errInvalidKey(key string) error {
+	return fmt.Errorf("blobs: invalid key %q", key)
+}
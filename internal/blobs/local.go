@@ -0,0 +1,94 @@
+package blobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps blobs as files in a directory on the local filesystem, spread
+// over subdirectories named after the first two characters of their key.
+type Local struct {
+	Dir string
+}
+
+// NewLocal returns a Local store in dir, creating it if needed.
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &Local{Dir: dir}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", errInvalidKey(key)
+	}
+	return filepath.Join(l.Dir, key[:2], key), nil
+}
+
+// Put writes the blob to a temporary file first and renames it into place, so
+// a failed upload never leaves half a blob under key. The filesystem can't be
+// interrupted, so ctx is only checked before the blob is put in place.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if n != size {
+		f.Close()
+		return fmt.Errorf("blobs: read %d bytes for %s, expected %d", n, key, size)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
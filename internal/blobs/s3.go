@@ -0,0 +1,87 @@
+package blobs
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 keeps blobs as objects in a bucket of an S3 compatible object store,
+// AWS S3 itself or something like MinIO.
+type S3 struct {
+	Client *minio.Client
+	Bucket string
+}
+
+// S3Config is what's needed to reach a bucket.
+type S3Config struct {
+	Endpoint  string // host[:port], without a scheme
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Insecure  bool // plain http, for a local stand-in
+}
+
+// NewS3 connects to the object store and creates the bucket if it doesn't
+// exist yet, so a wrong endpoint or bad credentials show up at startup.
+func NewS3(cfg S3Config) (*S3, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: !cfg.Insecure,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{}); err != nil {
+			return nil, err
+		}
+	}
+	return &S3{Client: client, Bucket: cfg.Bucket}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if !validKey(key) {
+		return errInvalidKey(key)
+	}
+	_, err := s.Client.PutObject(ctx, s.Bucket, key, r, size, minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return err
+}
+
+// Get stats the object first, GetObject itself doesn't fail for a missing
+// key until the first read.
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, errInvalidKey(key)
+	}
+	obj, err := s.Client.GetObject(ctx, s.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Delete relies on S3 treating the removal of a missing object as a success.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return errInvalidKey(key)
+	}
+	return s.Client.RemoveObject(ctx, s.Bucket, key, minio.RemoveObjectOptions{})
+}
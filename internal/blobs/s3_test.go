@@ -0,0 +1,117 @@
+package blobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestS3 connects to the object store in S3_TEST_ENDPOINT, a local MinIO
+// for instance:
+//
+//	S3_TEST_ENDPOINT=localhost:9000 S3_TEST_ACCESS_KEY=minioadmin \
+//	S3_TEST_SECRET_KEY=minioadmin S3_TEST_INSECURE=true go test ./internal/blobs
+//
+// S3_TEST_BUCKET picks the bucket, snippetbox-test by default. The tests are
+// skipped without an endpoint.
+func newTestS3(t *testing.T) *S3 {
+	t.Helper()
+
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set")
+	}
+	bucket := os.Getenv("S3_TEST_BUCKET")
+	if bucket == "" {
+		bucket = "snippetbox-test"
+	}
+	insecure, _ := strconv.ParseBool(os.Getenv("S3_TEST_INSECURE"))
+
+	s, err := NewS3(S3Config{
+		Endpoint:  endpoint,
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		Bucket:    bucket,
+		Insecure:  insecure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newTestKey(t *testing.T) string {
+	t.Helper()
+
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestS3PutGetDelete(t *testing.T) {
+	s := newTestS3(t)
+	ctx := context.Background()
+	key := newTestKey(t)
+	want := "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.\n"
+
+	if err := s.Put(ctx, key, strings.NewReader(want), int64(len(want))); err != nil {
+		t.Fatalf("Put: %s", err)
+	}
+	t.Cleanup(func() { s.Delete(context.Background(), key) })
+
+	rc, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("reading the blob: %s", err)
+	}
+	if string(got) != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: got %v; want ErrNotFound", err)
+	}
+	// a blob that's already gone isn't an error
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a deleted blob: %s", err)
+	}
+}
+
+func TestS3GetMissing(t *testing.T) {
+	s := newTestS3(t)
+
+	_, err := s.Get(context.Background(), newTestKey(t))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v; want ErrNotFound", err)
+	}
+}
+
+func TestS3InvalidKey(t *testing.T) {
+	s := newTestS3(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"", "../secret", "ABCDEF"} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1); err == nil {
+			t.Errorf("Put(%q): got no error", key)
+		}
+		if _, err := s.Get(ctx, key); err == nil {
+			t.Errorf("Get(%q): got no error", key)
+		}
+		if err := s.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q): got no error", key)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+// Package cache keeps copies of query results for a while, either in the
+// process or in a store shared by every instance of the app.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned by Get for a key that isn't cached, or no longer is.
+var ErrMiss = errors.New("cache: miss")
+
+// Cache maps keys to opaque values that expire after a ttl.
+type Cache interface {
+	// Get returns the value stored under key.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key for ttl, replacing what was there.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes keys, ones that aren't cached are ignored.
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process Cache holding at most MaxBytes of keys and values.
+// When it's full the least recently used entries make room. It's only
+// consistent within one process: another instance of the app can't
+// invalidate it.
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // front is the most recently used
+	entries  map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func (e *lruEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// NewLRU returns an empty LRU that holds up to maxBytes.
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	e := el.Value.(*lruEntry)
+	if !time.Now().Before(e.expires) {
+		c.remove(el)
+		return nil, ErrMiss
+	}
+	c.order.MoveToFront(el)
+	return e.value, nil
+}
+
+// Set doesn't store a value that would take up more than the whole cache.
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	e := &lruEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if ttl <= 0 || e.size() > c.maxBytes {
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(e)
+	c.size += e.size()
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+// remove needs c.mu held.
+func (c *LRU) remove(el *list.Element) {
+	e := c.order.Remove(el).(*lruEntry)
+	delete(c.entries, e.key)
+	c.size -= e.size()
+}
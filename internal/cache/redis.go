@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Cache kept in Redis, shared by every instance of the app so an
+// edit on one invalidates the copies all of them read.
+type Redis struct {
+	Client *redis.Client
+	Prefix string // put in front of every key, so the database can be shared
+}
+
+// NewRedis connects to the Redis server at url, redis://[user:pass@]host:port/db,
+// and pings it so a wrong address shows up at startup.
+func NewRedis(url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &Redis{Client: client, Prefix: "snippetbox:"}, nil
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.Client.Get(ctx, c.Prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+// Set skips ttls under a millisecond, the finest Redis has.
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return nil
+	}
+	return c.Client.Set(ctx, c.Prefix+key, value, ttl).Err()
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.Prefix + key
+	}
+	return c.Client.Del(ctx, prefixed...).Err()
+}
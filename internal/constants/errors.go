@@ -3,3 +3,16 @@ package constants
 import "errors"
 
 var ErrNoRecord = errors.New("models: no matching record found")
+
+var ErrInvalidCredentials = errors.New("models: invalid credentials")
+
+// ErrNotOwner is returned for a change to a snippet that someone else owns.
+var ErrNotOwner = errors.New("models: not the owner")
+
+// ErrTimeout and ErrCanceled are returned, wrapping the driver's own error,
+// when a query or a blob storage call is cut short: by the query timeout, or
+// because the caller's context was canceled, usually by a client that went
+// away.
+var ErrTimeout = errors.New("models: query timed out")
+
+var ErrCanceled = errors.New("models: query canceled")
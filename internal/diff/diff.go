@@ -0,0 +1,300 @@
+// Package diff computes line based diffs between two texts, using Myers'
+// O(ND) algorithm, and formats them as unified diffs or side-by-side rows.
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Myers' algorithm keeps a snapshot of the diagonals for every edit, so its
+// memory grows with the square of the number of edits and its time with the
+// lines times the edits. Lines gives up past these limits instead.
+const (
+	MaxLines = 10000 // lines of both texts together
+	MaxEdits = 1000  // lines deleted and inserted
+)
+
+// ErrTooLarge is returned by Lines for texts past MaxLines or MaxEdits.
+var ErrTooLarge = errors.New("diff: texts too large or too different to diff")
+
+type Kind int
+
+const (
+	Equal Kind = iota
+	Delete
+	Insert
+)
+
+// String returns the kind's name, which the templates use as a css class.
+func (k Kind) String() string {
+	switch k {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	}
+	return "equal"
+}
+
+// Sign returns the character that marks the kind in a unified diff.
+func (k Kind) Sign() string {
+	switch k {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	}
+	return " "
+}
+
+// Line is one line of a diff. A and B are the 1-based line numbers in the old
+// and new text, 0 when the line doesn't exist on that side.
+type Line struct {
+	Kind Kind
+	Text string
+	A    int
+	B    int
+}
+
+// Hunk is a run of changes with the unchanged lines around them.
+type Hunk struct {
+	AStart, ALen int
+	BStart, BLen int
+	Lines        []Line
+}
+
+// Row pairs the old and new side of a side-by-side diff, either side is nil
+// when the line only exists in the other text.
+type Row struct {
+	Left  *Line
+	Right *Line
+}
+
+// Lines diffs a and b line by line. Line endings are normalised first, so a
+// \r\n from a textarea doesn't count as a change.
+func Lines(a, b string) ([]Line, error) {
+	as, bs := split(a), split(b)
+	if len(as)+len(bs) > MaxLines {
+		return nil, ErrTooLarge
+	}
+	return myers(as, bs)
+}
+
+func split(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// myers finds a shortest edit script from a to b. For each number of edits d
+// it records the furthest reaching x on every diagonal k = x - y, then walks
+// those snapshots backwards from the end to recover the path. It stops with
+// ErrTooLarge when the script would need more than MaxEdits.
+func myers(a, b []string) ([]Line, error) {
+	n, m := len(a), len(b)
+	total := n + m
+	offset := total + 1
+	v := make([]int, 2*total+3)
+	trace := [][]int{}
+
+	for d := 0; d <= min(total, MaxEdits); d++ {
+		// snapshot of the diagonals -d..d as they were before this round
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // step down, an insertion
+			} else {
+				x = v[offset+k-1] + 1 // step right, a deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace), nil
+			}
+		}
+	}
+	return nil, ErrTooLarge
+}
+
+func backtrack(a, b []string, trace [][]int) []Line {
+	lines := []Line{}
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d > 0; d-- {
+		// trace[d] holds diagonals -d..d, so diagonal k is at index k+d
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			lines = append(lines, Line{Kind: Equal, Text: a[x-1], A: x, B: y})
+			x--
+			y--
+		}
+		if x == prevX {
+			lines = append(lines, Line{Kind: Insert, Text: b[y-1], B: y})
+		} else {
+			lines = append(lines, Line{Kind: Delete, Text: a[x-1], A: x})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		lines = append(lines, Line{Kind: Equal, Text: a[x-1], A: x, B: y})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// Hunks groups the changed lines into hunks with up to context unchanged
+// lines on either side. Hunks whose context would overlap are merged.
+func Hunks(lines []Line, context int) []Hunk {
+	hunks := []Hunk{}
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == Equal {
+			i++
+			continue
+		}
+		start := max(i-context, 0)
+		// extend past further changes until a long enough equal run is found
+		end := i
+		for end < len(lines) {
+			if lines[end].Kind != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Kind == Equal {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end = min(end+context, len(lines))
+				break
+			}
+			end = run
+		}
+		hunks = append(hunks, newHunk(lines[start:end]))
+		i = end
+	}
+	return hunks
+}
+
+func newHunk(lines []Line) Hunk {
+	h := Hunk{Lines: lines}
+	for _, l := range lines {
+		if l.Kind != Insert {
+			if h.AStart == 0 {
+				h.AStart = l.A
+			}
+			h.ALen++
+		}
+		if l.Kind != Delete {
+			if h.BStart == 0 {
+				h.BStart = l.B
+			}
+			h.BLen++
+		}
+	}
+	// an empty side starts at the line before the hunk, as diff(1) prints it
+	if h.ALen == 0 {
+		h.AStart = lineBefore(lines, func(l Line) int { return l.A })
+	}
+	if h.BLen == 0 {
+		h.BStart = lineBefore(lines, func(l Line) int { return l.B })
+	}
+	return h
+}
+
+func lineBefore(lines []Line, side func(Line) int) int {
+	for _, l := range lines {
+		if n := side(l); n > 0 {
+			return n - 1
+		}
+	}
+	return 0
+}
+
+// Header returns the @@ line for the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.AStart, h.ALen), hunkRange(h.BStart, h.BLen))
+}
+
+func hunkRange(start, n int) string {
+	if n == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
+
+// Rows lays the hunk out side by side. Deletions are paired up with the
+// insertions that follow them so a changed line sits next to its replacement.
+func (h Hunk) Rows() []Row {
+	rows := []Row{}
+	lines := h.Lines
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == Equal {
+			rows = append(rows, Row{Left: &lines[i], Right: &lines[i]})
+			i++
+			continue
+		}
+		dels, ins := []*Line{}, []*Line{}
+		for i < len(lines) && lines[i].Kind == Delete {
+			dels = append(dels, &lines[i])
+			i++
+		}
+		for i < len(lines) && lines[i].Kind == Insert {
+			ins = append(ins, &lines[i])
+			i++
+		}
+		for j := 0; j < max(len(dels), len(ins)); j++ {
+			row := Row{}
+			if j < len(dels) {
+				row.Left = dels[j]
+			}
+			if j < len(ins) {
+				row.Right = ins[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// Unified formats hunks as a unified diff, with from and to as the file names
+// in the --- and +++ header lines.
+func Unified(from, to string, hunks []Hunk) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", from, to)
+	for _, h := range hunks {
+		b.WriteString(h.Header())
+		b.WriteString("\n")
+		for _, l := range h.Lines {
+			b.WriteString(l.Kind.Sign())
+			b.WriteString(l.Text)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
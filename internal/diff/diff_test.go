@@ -0,0 +1,94 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string // the unified lines, sign and text
+	}{
+		{"Identical", "a\nb\n", "a\nb\n", " a\n b\n"},
+		{"Both empty", "", "", ""},
+		{"Insert", "a\nc", "a\nb\nc", " a\n+b\n c\n"},
+		{"Delete", "a\nb\nc", "a\nc", " a\n-b\n c\n"},
+		{"Change", "a\nb\nc", "a\nx\nc", " a\n-b\n+x\n c\n"},
+		{"From empty", "", "a\nb", "+a\n+b\n"},
+		{"Line endings", "a\r\nb\r\n", "a\nb\n", " a\n b\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := Lines(tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got strings.Builder
+			for _, l := range lines {
+				got.WriteString(l.Kind.Sign() + l.Text + "\n")
+			}
+			if got.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHunksUnified(t *testing.T) {
+	lines, err := Lines("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Unified("a", "b", Hunks(lines, 3))
+	want := "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// numbered returns n lines, each with prefix and its number.
+func numbered(prefix string, n int) string {
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, "%s %d\n", prefix, i)
+	}
+	return b.String()
+}
+
+func TestLinesLarge(t *testing.T) {
+	// long but alike texts are diffed
+	a := numbered("line", MaxLines/2)
+	b := strings.Replace(a, "line 2500\n", "changed\n", 1)
+	lines, err := Lines(a, b)
+	if err != nil {
+		t.Fatalf("one change in %d lines: %s", MaxLines, err)
+	}
+	if hunks := Hunks(lines, 3); len(hunks) != 1 {
+		t.Errorf("one change in %d lines: got %d hunks; want 1", MaxLines, len(hunks))
+	}
+
+	// unrelated ones give up without using up memory; without the limit
+	// these took hundreds of megabytes
+	a, b = numbered("old", 3000), numbered("new", 3000)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = Lines(a, b)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("unrelated texts: got %v; want ErrTooLarge", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 32<<20 {
+		t.Errorf("unrelated texts: allocated %d bytes", allocated)
+	}
+
+	// and so do texts with too many lines
+	if _, err := Lines(numbered("line", MaxLines), "line 0\n"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("%d lines: got %v; want ErrTooLarge", MaxLines+1, err)
+	}
+}
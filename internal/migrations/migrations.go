@@ -0,0 +1,248 @@
+// Package migrations holds the versioned schema changes for every supported
+// database, embedded into the binary so a fresh environment can be set up
+// with `web migrate up` instead of hand-run SQL.
+//
+// Files live in one directory per dialect and are named
+// NNNN_description.up.sql / NNNN_description.down.sql. Statements inside a
+// file are separated by a `;` at the end of a line, trigger bodies between
+// BEGIN and END; are left in one piece.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// per-dialect sql for the bookkeeping table, the only place where the
+// dialects differ outside of the migration files themselves
+var dialects = map[string]struct {
+	createTable string
+	insert      string
+	delete      string
+}{
+	"mysql": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY, applied_at DATETIME NOT NULL)`,
+		insert: `insert into schema_migrations (version, applied_at) values(?, UTC_TIMESTAMP())`,
+		delete: `delete from schema_migrations where version = ?`,
+	},
+	"postgres": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY, applied_at TIMESTAMPTZ NOT NULL)`,
+		insert: `insert into schema_migrations (version, applied_at) values($1, now())`,
+		delete: `delete from schema_migrations where version = $1`,
+	},
+	"sqlite": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY, applied_at DATETIME NOT NULL)`,
+		insert: `insert into schema_migrations (version, applied_at) values(?, datetime('now'))`,
+		delete: `delete from schema_migrations where version = ?`,
+	},
+}
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration along with whether it has been applied to the db.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+// Migrator applies the embedded migrations for Dialect ("mysql", "postgres"
+// or "sqlite") to DB.
+type Migrator struct {
+	DB      *sql.DB
+	Dialect string
+}
+
+// Up applies every pending migration in version order and returns the ones it ran.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+	for i, mig := range pending {
+		if err := m.apply(mig.Version, mig.Up, dialects[m.Dialect].insert); err != nil {
+			return pending[:i], fmt.Errorf("migrations: %04d_%s up: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+// Down rolls back the most recently applied migration. It returns nil if
+// nothing has been applied.
+func (m *Migrator) Down() (*Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+		mig := statuses[i].Migration
+		if err := m.apply(mig.Version, mig.Down, dialects[m.Dialect].delete); err != nil {
+			return nil, fmt.Errorf("migrations: %04d_%s down: %w", mig.Version, mig.Name, err)
+		}
+		return &mig, nil
+	}
+	return nil, nil
+}
+
+// Pending returns the migrations that haven't been applied yet.
+func (m *Migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	pending := []Migration{}
+	for _, s := range statuses {
+		if !s.Applied {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status() ([]Status, error) {
+	all, err := load(m.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.DB.Exec(dialects[m.Dialect].createTable); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]string{}
+	rows, err := m.DB.Query(`select version, applied_at from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(all))
+	for i, mig := range all {
+		at, ok := applied[mig.Version]
+		statuses[i] = Status{Migration: mig, Applied: ok, AppliedAt: at}
+	}
+	return statuses, nil
+}
+
+// apply runs the statements in script and the bookkeeping statement for
+// version inside one transaction. MySQL commits DDL implicitly, so there a
+// failed migration may be left half applied.
+func (m *Migrator) apply(version int, script string, bookkeeping string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once Commit has succeeded
+	defer tx.Rollback()
+
+	for _, stmt := range split(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(bookkeeping, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// load reads the embedded migrations for dialect, sorted by version.
+func load(dialect string) ([]Migration, error) {
+	if _, ok := dialects[dialect]; !ok {
+		return nil, fmt.Errorf("migrations: unsupported dialect %q", dialect)
+	}
+	names, err := fs.Glob(files, dialect+"/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []Migration{}
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".up.sql")
+		num, desc, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migrations: bad file name %s", name)
+		}
+		version, err := strconv.Atoi(num)
+		if err != nil {
+			return nil, fmt.Errorf("migrations: bad version in %s", name)
+		}
+		up, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+		down, err := fs.ReadFile(files, strings.TrimSuffix(name, ".up.sql")+".down.sql")
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: desc, Up: string(up), Down: string(down)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// split breaks a script into statements on lines ending with ';'. The MySQL
+// driver refuses multiple statements per Exec unless multiStatements is set.
+// Lines between one ending in BEGIN and one reading END; are kept together,
+// so trigger bodies survive.
+func split(script string) []string {
+	stmts := []string{}
+	var b strings.Builder
+	inBlock := false
+	for _, line := range strings.Split(script, "\n") {
+		b.WriteString(line)
+		b.WriteString("\n")
+
+		trimmed := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasSuffix(trimmed, "BEGIN"):
+			inBlock = true
+		case inBlock && trimmed == "END;":
+			inBlock = false
+		}
+		if !inBlock && strings.HasSuffix(trimmed, ";") {
+			if stmt := strings.TrimSpace(b.String()); stmt != ";" {
+				stmts = append(stmts, stmt)
+			}
+			b.Reset()
+		}
+	}
+	if stmt := strings.TrimSpace(b.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
@@ -0,0 +1,158 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "One statement",
+			script: "CREATE TABLE tags (id INTEGER);\n",
+			want:   []string{"CREATE TABLE tags (id INTEGER);"},
+		},
+		{
+			name:   "Several statements",
+			script: "ALTER TABLE snippets ADD COLUMN slug TEXT NULL;\nCREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);\n",
+			want: []string{
+				"ALTER TABLE snippets ADD COLUMN slug TEXT NULL;",
+				"CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);",
+			},
+		},
+		{
+			name:   "Statement over several lines",
+			script: "CREATE TABLE tags (\n    id INTEGER,\n    name TEXT\n);\n",
+			want:   []string{"CREATE TABLE tags (\n    id INTEGER,\n    name TEXT\n);"},
+		},
+		{
+			name:   "Semicolon inside a line",
+			script: "INSERT INTO tags (name) VALUES ('a;b');\n",
+			want:   []string{"INSERT INTO tags (name) VALUES ('a;b');"},
+		},
+		{
+			name: "Trigger body",
+			script: "CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN\n" +
+				"    INSERT INTO snippets_fts (rowid, title) VALUES (new.id, new.title);\n" +
+				"    INSERT INTO snippets_log (id) VALUES (new.id);\n" +
+				"END;\n" +
+				"DROP TABLE tags;\n",
+			want: []string{
+				"CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN\n" +
+					"    INSERT INTO snippets_fts (rowid, title) VALUES (new.id, new.title);\n" +
+					"    INSERT INTO snippets_log (id) VALUES (new.id);\n" +
+					"END;",
+				"DROP TABLE tags;",
+			},
+		},
+		{
+			name:   "Lowercase trigger",
+			script: "create trigger t after delete on snippets begin\n    delete from tags;\nend;\n",
+			want:   []string{"create trigger t after delete on snippets begin\n    delete from tags;\nend;"},
+		},
+		{
+			name:   "Comments and blank lines",
+			script: "-- a comment\n\nDROP TABLE tags;\n\n",
+			want:   []string{"-- a comment\n\nDROP TABLE tags;"},
+		},
+		{
+			name:   "No trailing semicolon",
+			script: "DROP TABLE tags;\nDROP TABLE snippets",
+			want:   []string{"DROP TABLE tags;", "DROP TABLE snippets"},
+		},
+		{
+			name:   "Stray semicolon",
+			script: "DROP TABLE tags;\n;\n",
+			want:   []string{"DROP TABLE tags;"},
+		},
+		{
+			name:   "Empty",
+			script: "",
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := split(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	for dialect := range dialects {
+		t.Run(dialect, func(t *testing.T) {
+			all, err := load(dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, mig := range all {
+				if mig.Version != i+1 {
+					t.Fatalf("migration %d is %04d_%s; want versions numbered from 1 without gaps", i, mig.Version, mig.Name)
+				}
+				if mig.Up == "" || mig.Down == "" {
+					t.Errorf("%04d_%s: empty up or down script", mig.Version, mig.Name)
+				}
+			}
+		})
+	}
+	if _, err := load("oracle"); err == nil {
+		t.Error("loading an unsupported dialect: got no error")
+	}
+}
+
+// TestUpDown runs every SQLite migration up, all the way back down and up
+// again, which the other dialects can't do without a server.
+func TestUpDown(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db")+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m := &Migrator{DB: db, Dialect: "sqlite"}
+
+	all, err := load("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != len(all) {
+		t.Fatalf("up ran %d migrations; want %d", len(ran), len(all))
+	}
+
+	for i := len(all) - 1; i >= 0; i-- {
+		mig, err := m.Down()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mig == nil || mig.Version != all[i].Version {
+			t.Fatalf("down rolled back %v; want %04d_%s", mig, all[i].Version, all[i].Name)
+		}
+	}
+	if mig, err := m.Down(); mig != nil || err != nil {
+		t.Fatalf("down with nothing applied: got %v, %v; want nothing", mig, err)
+	}
+
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("got %d pending migrations after up; want none", len(pending))
+	}
+}
@@ -0,0 +1,3 @@
+DROP INDEX ft_snippets_title_content ON snippets;
+DROP INDEX ft_snippets_content ON snippets;
+DROP INDEX ft_snippets_title ON snippets;
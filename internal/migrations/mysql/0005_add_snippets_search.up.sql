@@ -0,0 +1,3 @@
+CREATE FULLTEXT INDEX ft_snippets_title ON snippets (title);
+CREATE FULLTEXT INDEX ft_snippets_content ON snippets (content);
+CREATE FULLTEXT INDEX ft_snippets_title_content ON snippets (title, content);
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN password_hash VARCHAR(60) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE snippets DROP COLUMN owner;
+ALTER TABLE snippets DROP COLUMN visibility;
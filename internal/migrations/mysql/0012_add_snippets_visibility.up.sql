@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN owner VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP INDEX idx_snippets_slug ON snippets;
+ALTER TABLE snippets DROP COLUMN slug;
@@ -0,0 +1,17 @@
+-- Existing snippets get slugs of the same shape the app makes, 10 characters
+-- from [a-zA-Z0-9], each picked with two bytes from RANDOM_BYTES so the
+-- modulo bias is negligible.
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(32) NULL;
+UPDATE snippets SET slug = CONCAT(
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1),
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1),
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1),
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1),
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1),
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1),
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1),
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1),
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1),
+    SUBSTRING('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', CONV(HEX(RANDOM_BYTES(2)), 16, 10) % 62 + 1, 1)
+);
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
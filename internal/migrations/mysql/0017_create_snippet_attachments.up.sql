@@ -0,0 +1,14 @@
+-- Purging a snippet leaves its attachments behind with snippet_id set to NULL,
+-- the reaper deletes their blobs and then the rows.
+CREATE TABLE snippet_attachments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NULL,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    blob_key VARCHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_attachments_uc_blob_key UNIQUE (blob_key),
+    INDEX idx_snippet_attachments_snippet (snippet_id),
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE SET NULL
+);
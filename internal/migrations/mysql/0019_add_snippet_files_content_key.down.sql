@@ -0,0 +1 @@
+ALTER TABLE snippet_files DROP COLUMN content_key;
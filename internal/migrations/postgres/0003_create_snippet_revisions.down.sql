@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS snippet_revisions;
+ALTER TABLE snippets DROP COLUMN revision;
@@ -0,0 +1,12 @@
+ALTER TABLE snippets ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+CREATE TABLE snippet_revisions (
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (snippet_id, revision),
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE
+);
+INSERT INTO snippet_revisions (snippet_id, revision, title, content, created)
+SELECT id, 1, title, content, created FROM snippets;
@@ -0,0 +1,3 @@
+CREATE INDEX idx_snippets_search ON snippets USING GIN (
+    (setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B'))
+);
@@ -0,0 +1,13 @@
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(20) NOT NULL,
+    CONSTRAINT tags_uc_name UNIQUE (name)
+);
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id),
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_snippet_tags_tag ON snippet_tags (tag_id, snippet_id);
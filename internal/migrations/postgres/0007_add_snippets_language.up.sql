@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN language VARCHAR(40) NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+-- Existing snippets get slugs of the same shape the app makes, 10 characters
+-- from [a-zA-Z0-9], each picked with two bytes from pgcrypto's strong random
+-- source so the modulo bias is negligible.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(32) NULL;
+UPDATE snippets SET slug =
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', ('x' || encode(gen_random_bytes(2), 'hex'))::bit(16)::int % 62 + 1, 1);
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
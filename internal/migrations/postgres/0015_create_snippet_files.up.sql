@@ -0,0 +1,10 @@
+ALTER TABLE snippets ADD COLUMN filename VARCHAR(100) NOT NULL DEFAULT '';
+CREATE TABLE snippet_files (
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(40) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    PRIMARY KEY (snippet_id, position),
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN deleted_at DATETIME NULL;
+CREATE INDEX idx_snippets_deleted_at ON snippets (deleted_at);
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN language_guessed BOOLEAN NOT NULL DEFAULT 0;
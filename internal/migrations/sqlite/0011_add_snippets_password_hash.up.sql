@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN owner TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,63 @@
+-- SQLite can't drop NOT NULL in place, so snippets is rebuilt. Foreign keys are
+-- on for every connection and can't be turned off inside the migration's
+-- transaction, so the tables referencing snippets are set aside first,
+-- otherwise dropping snippets would cascade to them.
+CREATE TABLE snippet_revisions_old AS SELECT * FROM snippet_revisions;
+CREATE TABLE snippet_tags_old AS SELECT * FROM snippet_tags;
+DROP TABLE snippet_revisions;
+DROP TABLE snippet_tags;
+CREATE TABLE snippets_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NULL,
+    revision INTEGER NOT NULL DEFAULT 1,
+    deleted_at DATETIME NULL,
+    language TEXT NOT NULL DEFAULT '',
+    language_guessed BOOLEAN NOT NULL DEFAULT 0,
+    burn_after_reading BOOLEAN NOT NULL DEFAULT 0,
+    password_hash TEXT NOT NULL DEFAULT '',
+    visibility TEXT NOT NULL DEFAULT 'public',
+    owner TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO snippets_new (id, title, content, created, expires, revision, deleted_at, language, language_guessed, burn_after_reading, password_hash, visibility, owner)
+SELECT id, title, content, created, expires, revision, deleted_at, language, language_guessed, burn_after_reading, password_hash, visibility, owner FROM snippets;
+-- keep handing out ids after the highest one ever used, not the highest left
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'snippets') WHERE name = 'snippets_new';
+DROP TABLE snippets;
+ALTER TABLE snippets_new RENAME TO snippets;
+CREATE INDEX idx_snippets_created ON snippets (created);
+CREATE INDEX idx_snippets_deleted_at ON snippets (deleted_at);
+CREATE INDEX idx_snippets_expires ON snippets (expires);
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    INSERT INTO snippets_fts (snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, content ON snippets BEGIN
+    INSERT INTO snippets_fts (snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO snippets_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+CREATE TABLE snippet_revisions (
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, revision),
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE
+);
+INSERT INTO snippet_revisions SELECT * FROM snippet_revisions_old;
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id),
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+INSERT INTO snippet_tags SELECT * FROM snippet_tags_old;
+CREATE INDEX idx_snippet_tags_tag ON snippet_tags (tag_id, snippet_id);
+DROP TABLE snippet_revisions_old;
+DROP TABLE snippet_tags_old;
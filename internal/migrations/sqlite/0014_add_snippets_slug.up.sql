@@ -0,0 +1,16 @@
+-- Existing snippets get slugs of the same shape the app makes, 10 characters
+-- from [a-zA-Z0-9]. random() draws from the same source as randomblob() and
+-- is 64 bits wide, so the modulo bias is negligible.
+ALTER TABLE snippets ADD COLUMN slug TEXT NULL;
+UPDATE snippets SET slug =
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1) ||
+    substr('abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789', abs(random() % 62) + 1, 1);
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
@@ -0,0 +1,5 @@
+-- No foreign key here: SQLite can't drop a column that has one without
+-- rebuilding the table, and a fork of a purged snippet is shown without a
+-- link either way.
+ALTER TABLE snippets ADD COLUMN forked_from INTEGER NULL;
+CREATE INDEX idx_snippets_forked_from ON snippets (forked_from);
@@ -0,0 +1,14 @@
+-- Purging a snippet leaves its attachments behind with snippet_id set to NULL,
+-- the reaper deletes their blobs and then the rows.
+CREATE TABLE snippet_attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NULL,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    blob_key TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_attachments_uc_blob_key UNIQUE (blob_key),
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE SET NULL
+);
+CREATE INDEX idx_snippet_attachments_snippet ON snippet_attachments (snippet_id);
@@ -0,0 +1,3 @@
+DROP TABLE snippet_content_blobs;
+ALTER TABLE snippet_revisions DROP COLUMN content_key;
+ALTER TABLE snippets DROP COLUMN content_key;
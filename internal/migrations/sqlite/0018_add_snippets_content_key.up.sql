@@ -0,0 +1,11 @@
+-- Content above the configured threshold is kept in blob storage, the row and
+-- its revisions have the key and the start of the content. snippet_content_blobs tracks every such
+-- blob, purging a snippet sets snippet_id to NULL for the reaper to clean up.
+ALTER TABLE snippets ADD COLUMN content_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE snippet_revisions ADD COLUMN content_key TEXT NOT NULL DEFAULT '';
+CREATE TABLE snippet_content_blobs (
+    blob_key TEXT PRIMARY KEY,
+    snippet_id INTEGER NULL,
+    FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE SET NULL
+);
+CREATE INDEX idx_snippet_content_blobs_snippet ON snippet_content_blobs (snippet_id);
@@ -0,0 +1,4 @@
+-- Files of a snippet are offloaded like its content, the row keeps the first
+-- -content-threshold bytes and the key. Their blobs are tracked in
+-- snippet_content_blobs along with the snippet's own.
+ALTER TABLE snippet_files ADD COLUMN content_key TEXT NOT NULL DEFAULT '';
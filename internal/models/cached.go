@@ -0,0 +1,317 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"snippetbox.tushar.net/internal/cache"
+)
+
+// CachedStore is a read-through cache in front of a SnippetStore for Get,
+// GetBySlug and Latest. Entries live for TTL and never past the expiry of a
+// snippet in them. Burn after reading snippets aren't cached, they're read
+// once, and neither are password protected ones, so their content is never
+// in a cache that may be shared.
+//
+// Entries are kept under a generation of their key that the writes of this
+// store move on, see invalidate, so a read that raced a write can't put the
+// old version back. If the cache can't be reached for the write, the old
+// version is served until its TTL runs out.
+type CachedStore struct {
+	Store SnippetStore
+	Cache cache.Cache
+	TTL   time.Duration
+	// Quiet is how long after a write what's read for the keys it changed
+	// isn't cached, so copies from replicas that haven't caught up yet don't
+	// end up in it. 0 without replicas.
+	Quiet time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// CacheStats counts the reads served from the cache and the ones that went
+// to the store since startup.
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+func (c *CachedStore) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+const latestKey = "latest"
+
+func snippetKey(id int) string {
+	return fmt.Sprintf("snippet:%d", id)
+}
+
+func slugKey(slug string) string {
+	return "slug:" + slug
+}
+
+func generationKey(key string) string {
+	return "gen:" + key
+}
+
+// newGeneration returns a generation no other key or instance has, the time
+// it was made and random bytes.
+func newGeneration() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("%d.%x", time.Now().UnixNano(), b)
+}
+
+// generation returns the generation entries for key are cached under, and
+// whether it was made within Quiet. A key without one, never written or
+// dropped from the cache, gets a new one, which leaves whatever was cached
+// for it before unread. That may replace the generation of a write made in
+// the meantime, so it's quiet for as long.
+func (c *CachedStore) generation(ctx context.Context, key string) (gen string, quiet bool) {
+	b, err := c.Cache.Get(ctx, generationKey(key))
+	if err != nil {
+		gen = newGeneration()
+		c.Cache.Set(ctx, generationKey(key), []byte(gen), c.TTL+c.Quiet)
+		return gen, c.Quiet > 0
+	}
+	gen = string(b)
+	made, _, _ := strings.Cut(gen, ".")
+	nanos, _ := strconv.ParseInt(made, 10, 64)
+	return gen, time.Since(time.Unix(0, nanos)) < c.Quiet
+}
+
+// invalidate moves keys on to a new generation after a write, even when the
+// client that made it has gone by now. Reads take the generation before they
+// go to the store, so one that started before the write caches what it got
+// under the old generation, where nobody looks any more.
+func (c *CachedStore) invalidate(ctx context.Context, keys ...string) {
+	ctx = context.WithoutCancel(ctx)
+	gen := []byte(newGeneration())
+	for _, key := range keys {
+		c.Cache.Set(ctx, generationKey(key), gen, c.TTL+c.Quiet)
+	}
+}
+
+// ttl is how long something holding snippets that expire at expires may be
+// cached, 0 or less for not at all.
+func (c *CachedStore) ttl(expires ...time.Time) time.Duration {
+	ttl := c.TTL
+	for _, t := range expires {
+		if !t.IsZero() {
+			ttl = min(ttl, time.Until(t))
+		}
+	}
+	return ttl
+}
+
+// load decodes the value cached under key into v. Anything but a clean hit,
+// a broken entry too, is a miss and the store is asked instead.
+func (c *CachedStore) load(ctx context.Context, key string, v any) bool {
+	b, err := c.Cache.Get(ctx, key)
+	if err != nil {
+		return false
+	}
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v) == nil
+}
+
+// save caches v under key for ttl. A failure only costs a later miss, so it
+// isn't reported.
+func (c *CachedStore) save(ctx context.Context, key string, v any, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return
+	}
+	c.Cache.Set(ctx, key, buf.Bytes(), ttl)
+}
+
+// Reads from the primary, see ReadPrimary, aren't served from the cache: the
+// session expects to see its own write, which a copy cached from a replica
+// may not have yet. What they read is current though, so it's cached even
+// within Quiet.
+
+func (c *CachedStore) Get(ctx context.Context, id int) (*Snippet, error) {
+	key := snippetKey(id)
+	gen, quiet := c.generation(ctx, key)
+	var s Snippet
+	if !readsPrimary(ctx) && c.load(ctx, key+"@"+gen, &s) && !s.expired(time.Now()) {
+		c.hits.Add(1)
+		return &s, nil
+	}
+	c.misses.Add(1)
+
+	snippet, err := c.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !quiet || readsPrimary(ctx) {
+		c.cache(ctx, key+"@"+gen, snippet)
+	}
+	return snippet, nil
+}
+
+// GetBySlug caches the id a slug belongs to, which never changes, and goes
+// through Get for the snippet so that there's one copy to invalidate. The
+// snippet read on a miss isn't cached, its generation wasn't taken first.
+func (c *CachedStore) GetBySlug(ctx context.Context, slug string) (*Snippet, error) {
+	var id int
+	if c.load(ctx, slugKey(slug), &id) {
+		return c.Get(ctx, id)
+	}
+	c.misses.Add(1)
+
+	snippet, err := c.Store.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	c.save(ctx, slugKey(slug), snippet.ID, c.ttl(snippet.Expires))
+	return snippet, nil
+}
+
+// cache saves a copy of s that only has a hash of its owner token, so the
+// token itself never leaves the process; Snippet.OwnedBy checks either.
+func (c *CachedStore) cache(ctx context.Context, key string, s *Snippet) {
+	if s.BurnAfterReading || s.Protected {
+		return
+	}
+	copy := *s
+	copy.Owner = hashOwner(s.Owner)
+	c.save(ctx, key, &copy, c.ttl(s.Expires))
+}
+
+// Latest entries are cached without content, the list doesn't show it and a
+// protected snippet's would be in there.
+func (c *CachedStore) Latest(ctx context.Context) ([]*Snippet, error) {
+	gen, quiet := c.generation(ctx, latestKey)
+	var snippets []*Snippet
+	if !readsPrimary(ctx) && c.load(ctx, latestKey+"@"+gen, &snippets) && !anyExpired(snippets, time.Now()) {
+		c.hits.Add(1)
+		return snippets, nil
+	}
+	c.misses.Add(1)
+
+	snippets, err := c.Store.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !quiet || readsPrimary(ctx) {
+		copies := []*Snippet{}
+		expires := []time.Time{}
+		for _, s := range snippets {
+			copy := *s
+			copy.Content = ""
+			copies = append(copies, &copy)
+			expires = append(expires, s.Expires)
+		}
+		c.save(ctx, latestKey+"@"+gen, copies, c.ttl(expires...))
+	}
+	return snippets, nil
+}
+
+func anyExpired(snippets []*Snippet, now time.Time) bool {
+	for _, s := range snippets {
+		if s.expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CachedStore) Insert(ctx context.Context, n NewSnippet) (int, string, error) {
+	id, slug, err := c.Store.Insert(ctx, n)
+	if err == nil {
+		c.invalidate(ctx, latestKey)
+	}
+	return id, slug, err
+}
+
+func (c *CachedStore) Update(ctx context.Context, id int, title string, content string, language string, guessed bool) (int, error) {
+	revision, err := c.Store.Update(ctx, id, title, content, language, guessed)
+	if err == nil {
+		c.invalidate(ctx, snippetKey(id), latestKey)
+	}
+	return revision, err
+}
+
+func (c *CachedStore) Delete(ctx context.Context, id int) error {
+	err := c.Store.Delete(ctx, id)
+	if err == nil {
+		c.invalidate(ctx, snippetKey(id), latestKey)
+	}
+	return err
+}
+
+func (c *CachedStore) Restore(ctx context.Context, slug string, grace time.Duration, owner string) (int, error) {
+	id, err := c.Store.Restore(ctx, slug, grace, owner)
+	if err == nil {
+		c.invalidate(ctx, snippetKey(id), latestKey)
+	}
+	return id, err
+}
+
+func (c *CachedStore) Burn(ctx context.Context, id int) (*Snippet, error) {
+	s, err := c.Store.Burn(ctx, id)
+	if err == nil {
+		c.invalidate(ctx, snippetKey(id))
+	}
+	return s, err
+}
+
+// Purged snippets are expired or in the trash, neither is ever served from
+// the cache, so purges don't invalidate anything.
+
+func (c *CachedStore) PurgeDeleted(ctx context.Context, grace time.Duration) (int, error) {
+	return c.Store.PurgeDeleted(ctx, grace)
+}
+
+func (c *CachedStore) PurgeExpired(ctx context.Context, limit int) (int, error) {
+	return c.Store.PurgeExpired(ctx, limit)
+}
+
+// The rest isn't cached.
+
+func (c *CachedStore) Page(ctx context.Context, tag string, before int, after int, size int) ([]*Snippet, bool, error) {
+	return c.Store.Page(ctx, tag, before, after, size)
+}
+
+func (c *CachedStore) Search(ctx context.Context, query string, limit int) ([]*Snippet, error) {
+	return c.Store.Search(ctx, query, limit)
+}
+
+func (c *CachedStore) Revisions(ctx context.Context, id int) ([]*Revision, error) {
+	return c.Store.Revisions(ctx, id)
+}
+
+func (c *CachedStore) GetRevision(ctx context.Context, id int, revision int) (*Revision, error) {
+	return c.Store.GetRevision(ctx, id, revision)
+}
+
+func (c *CachedStore) Trash(ctx context.Context, grace time.Duration, owner string) ([]*Snippet, error) {
+	return c.Store.Trash(ctx, grace, owner)
+}
+
+func (c *CachedStore) CheckPassword(ctx context.Context, id int, password string) error {
+	return c.Store.CheckPassword(ctx, id, password)
+}
+
+func (c *CachedStore) Forks(ctx context.Context, id int) ([]*Snippet, error) {
+	return c.Store.Forks(ctx, id)
+}
+
+func (c *CachedStore) OrphanedBlobs(ctx context.Context, limit int) ([]string, error) {
+	return c.Store.OrphanedBlobs(ctx, limit)
+}
+
+func (c *CachedStore) DeleteBlobs(ctx context.Context, keys []string) error {
+	return c.Store.DeleteBlobs(ctx, keys)
+}
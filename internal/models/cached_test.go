@@ -0,0 +1,222 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"snippetbox.tushar.net/internal/cache"
+	"snippetbox.tushar.net/internal/constants"
+)
+
+// ttlCache is an LRU that remembers the ttl of the last Set of every key.
+type ttlCache struct {
+	*cache.LRU
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+}
+
+func (c *ttlCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	c.ttls[key] = ttl
+	c.mu.Unlock()
+	return c.LRU.Set(ctx, key, value, ttl)
+}
+
+// entries returns the ttls of the cached copies of snippet id, leaving out
+// its generation.
+func (c *ttlCache) entries(id int) []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ttls := []time.Duration{}
+	for key, ttl := range c.ttls {
+		if strings.HasPrefix(key, snippetKey(id)+"@") {
+			ttls = append(ttls, ttl)
+		}
+	}
+	return ttls
+}
+
+func newTestCachedStore() (*CachedStore, *ttlCache) {
+	c := &ttlCache{LRU: cache.NewLRU(1 << 20), ttls: map[string]time.Duration{}}
+	return &CachedStore{Store: NewMemorySnippetModel(), Cache: c, TTL: time.Hour}, c
+}
+
+const testOwner = "owner-token"
+
+func insertTestSnippet(t *testing.T, c *CachedStore, n NewSnippet) (int, string) {
+	t.Helper()
+
+	if n.Title == "" {
+		n.Title, n.Content = "An old silent pond", "An old silent pond..."
+	}
+	n.Visibility, n.Owner = VisibilityPublic, testOwner
+	id, slug, err := c.Insert(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, slug
+}
+
+func TestCachedStoreGet(t *testing.T) {
+	c, _ := newTestCachedStore()
+	ctx := context.Background()
+	id, _ := insertTestSnippet(t, c, NewSnippet{})
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("got %d hits, %d misses; want 2, 1", stats.Hits, stats.Misses)
+	}
+}
+
+func TestCachedStoreInvalidate(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		write   func(c *CachedStore, id int, slug string) error
+		wantErr error
+		want    string
+	}{
+		{
+			name: "Update",
+			write: func(c *CachedStore, id int, slug string) error {
+				_, err := c.Update(ctx, id, "A frog jumps", "A frog jumps into the pond", "", false)
+				return err
+			},
+			want: "A frog jumps",
+		},
+		{
+			name: "Delete",
+			write: func(c *CachedStore, id int, slug string) error {
+				return c.Delete(ctx, id)
+			},
+			wantErr: constants.ErrNoRecord,
+		},
+		{
+			name: "Restore",
+			write: func(c *CachedStore, id int, slug string) error {
+				// deleted behind the cache's back, so that only the restore
+				// moves the generations on
+				if err := c.Store.Delete(ctx, id); err != nil {
+					return err
+				}
+				_, err := c.Restore(ctx, slug, time.Hour, testOwner)
+				return err
+			},
+			want: "An old silent pond",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCachedStore()
+			id, slug := insertTestSnippet(t, c, NewSnippet{})
+			if _, err := c.Get(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Latest(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.write(c, id, slug); err != nil {
+				t.Fatal(err)
+			}
+			misses := c.Stats().Misses
+
+			s, err := c.Get(ctx, id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if err == nil && s.Title != tt.want {
+				t.Errorf("got title %q; want %q", s.Title, tt.want)
+			}
+			latest, err := c.Latest(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Stats().Misses - misses; got != 2 {
+				t.Errorf("got %d misses after the write; want 2, for the snippet and the latest", got)
+			}
+			if tt.wantErr != nil && len(latest) != 0 {
+				t.Errorf("latest lists %d snippets; want none", len(latest))
+			}
+		})
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		c, _ := newTestCachedStore()
+		insertTestSnippet(t, c, NewSnippet{})
+		if _, err := c.Latest(ctx); err != nil {
+			t.Fatal(err)
+		}
+		insertTestSnippet(t, c, NewSnippet{Title: "A frog jumps", Content: "A frog jumps into the pond"})
+
+		latest, err := c.Latest(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(latest) != 2 || latest[0].Title != "A frog jumps" {
+			t.Errorf("got %d latest snippets; want both, the new one first", len(latest))
+		}
+	})
+}
+
+func TestCachedStoreTTL(t *testing.T) {
+	c, lru := newTestCachedStore()
+	ctx := context.Background()
+
+	expires := time.Now().Add(10 * time.Minute).Truncate(time.Minute)
+	soon, _ := insertTestSnippet(t, c, NewSnippet{Expires: expires})
+	never, _ := insertTestSnippet(t, c, NewSnippet{})
+	left := time.Until(expires)
+	for _, id := range []int{soon, never} {
+		if _, err := c.Get(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ttls := lru.entries(soon)
+	if len(ttls) != 1 || ttls[0] > left {
+		t.Errorf("expiring snippet cached for %v; want one entry until it expires, at most %v", ttls, left)
+	}
+	if ttls := lru.entries(never); len(ttls) != 1 || ttls[0] != c.TTL {
+		t.Errorf("snippet that never expires cached for %v; want one entry for %v", ttls, c.TTL)
+	}
+}
+
+func TestCachedStoreNotCached(t *testing.T) {
+	tests := []struct {
+		name string
+		n    NewSnippet
+	}{
+		{"Burn after reading", NewSnippet{BurnAfterReading: true}},
+		{"Password protected", NewSnippet{Password: "pond"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, lru := newTestCachedStore()
+			ctx := context.Background()
+			id, _ := insertTestSnippet(t, c, tt.n)
+
+			for i := 0; i < 2; i++ {
+				if _, err := c.Get(ctx, id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if ttls := lru.entries(id); len(ttls) != 0 {
+				t.Errorf("got %d cached copies; want none", len(ttls))
+			}
+			if hits := c.Stats().Hits; hits != 0 {
+				t.Errorf("got %d hits; want none", hits)
+			}
+		})
+	}
+}
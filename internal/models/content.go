@@ -0,0 +1,94 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"snippetbox.tushar.net/internal/blobs"
+)
+
+// offloadContent moves content longer than threshold bytes into blob storage.
+// It returns what's left for the content column, the first threshold bytes
+// so that search still has something to go on, and the blob key, which is
+// empty when content stays in the row.
+func offloadContent(ctx context.Context, store blobs.Store, threshold int, content string) (string, string, error) {
+	if store == nil || threshold <= 0 || len(content) <= threshold {
+		return content, "", nil
+	}
+	key, err := blobs.NewKey()
+	if err != nil {
+		return "", "", err
+	}
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content))); err != nil {
+		return "", "", ContextError(ctx, err)
+	}
+	return prefix(content, threshold), key, nil
+}
+
+// offloadFiles is offloadContent for each of files. It returns a copy of
+// files with what's left of their content and a key for every file, empty for
+// those kept in the row. Nothing is left in blob storage if it fails.
+func offloadFiles(ctx context.Context, store blobs.Store, threshold int, files []File) ([]File, []string, error) {
+	kept := make([]File, len(files))
+	keys := make([]string, len(files))
+	for i, f := range files {
+		content, key, err := offloadContent(ctx, store, threshold, f.Content)
+		if err != nil {
+			discardContent(ctx, store, keys...)
+			return nil, nil, err
+		}
+		f.Content = content
+		kept[i], keys[i] = f, key
+	}
+	return kept, keys, nil
+}
+
+// prefix returns at most n bytes from the start of s, without splitting a
+// UTF-8 sequence.
+func prefix(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// discardContent deletes blobs offloadContent stored when the row that was
+// meant to reference them wasn't written. It's only a clean up, a blob that
+// can't be deleted is left behind and the original error is what matters.
+// It still runs when ctx was canceled, which is often why the row wasn't
+// written.
+func discardContent(ctx context.Context, store blobs.Store, keys ...string) {
+	for _, key := range keys {
+		if key != "" {
+			store.Delete(context.WithoutCancel(ctx), key)
+		}
+	}
+}
+
+// loadContent returns the content of a row, reading it from blob storage if
+// the row only has a key.
+func loadContent(ctx context.Context, store blobs.Store, content string, key string) (string, error) {
+	if key == "" {
+		return content, nil
+	}
+	if store == nil {
+		return "", errors.New("models: content is in blob storage, but none is configured")
+	}
+	blob, err := store.Get(ctx, key)
+	if err != nil {
+		return "", ContextError(ctx, err)
+	}
+	defer blob.Close()
+
+	b, err := io.ReadAll(blob)
+	if err != nil {
+		return "", ContextError(ctx, err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"snippetbox.tushar.net/internal/blobs"
+	"snippetbox.tushar.net/internal/migrations"
+)
+
+// newTestSQLite returns a migrated SQLite database in a temporary directory.
+func newTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m := &migrations.Migrator{DB: db, Dialect: "sqlite"}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"Shorter", "pond", 10, "pond"},
+		{"Exact", "pond", 4, "pond"},
+		{"Cut", "an old pond", 6, "an old"},
+		{"Inside a rune", "古池や", 4, "古"},
+		{"At a rune", "古池や", 6, "古池"},
+		{"Before the first rune", "古池や", 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prefix(tt.s, tt.n); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffloadContent(t *testing.T) {
+	db := newTestSQLite(t)
+	store, err := blobs.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &SQLiteSnippetModel{DB: db, Blobs: store, ContentThreshold: 32}
+	ctx := context.Background()
+
+	content := "An old silent pond, " + strings.Repeat("a frog jumps in, ", 10) + "splash!"
+	file := "Autumn moonlight, " + strings.Repeat("a worm digs silently ", 10)
+	id, _, err := m.Insert(ctx, NewSnippet{
+		Title:      "Haiku",
+		Content:    content,
+		Visibility: VisibilityPublic,
+		Files:      []File{{Name: "moon.txt", Content: file}, {Name: "short.txt", Content: "Short"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rowContent, rowKey string
+	err = db.QueryRow(`select content, content_key from snippets where id = ?`, id).Scan(&rowContent, &rowKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rowContent != content[:32] || rowKey == "" {
+		t.Errorf("snippet row: got content %q, key %q; want the first 32 bytes and a key", rowContent, rowKey)
+	}
+	var fileContent, fileKey string
+	err = db.QueryRow(`select content, content_key from snippet_files where snippet_id = ? and position = 1`, id).Scan(&fileContent, &fileKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileContent != file[:32] || fileKey == "" {
+		t.Errorf("file row: got content %q, key %q; want the first 32 bytes and a key", fileContent, fileKey)
+	}
+	var tracked int
+	if err = db.QueryRow(`select count(*) from snippet_content_blobs where snippet_id = ?`, id).Scan(&tracked); err != nil {
+		t.Fatal(err)
+	}
+	if tracked != 2 {
+		t.Errorf("got %d tracked blobs; want 2", tracked)
+	}
+
+	s, err := m.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Content != content {
+		t.Errorf("got content %q; want %q", s.Content, content)
+	}
+	if len(s.Files) != 2 || s.Files[0].Content != file || s.Files[1].Content != "Short" {
+		t.Errorf("got files %+v; want the full contents", s.Files)
+	}
+
+	found, err := m.Search(ctx, "silent", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != id {
+		t.Errorf("searching the start of offloaded content: got %d results; want the snippet", len(found))
+	}
+}
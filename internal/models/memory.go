@@ -0,0 +1,496 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"snippetbox.tushar.net/internal/constants"
+)
+
+// MemorySnippetModel keeps snippets in a map guarded by a mutex. It mirrors the
+// behaviour of SnippetModel (expired snippets are hidden, Latest returns the 10
+// newest) so the app and its handlers can run without a database.
+type MemorySnippetModel struct {
+	mu        sync.RWMutex
+	snippets  map[int]*Snippet
+	revisions map[int][]*Revision
+	passwords map[int]string // bcrypt hashes of protected snippets
+	slugs     map[string]int // slug to id
+	orphans   []string       // blob keys of the attachments of removed snippets
+	nextID    int
+
+	nextAttachmentID int
+
+	SlugLength int // length of new slugs, DefaultSlugLength if 0
+}
+
+func NewMemorySnippetModel() *MemorySnippetModel {
+	return &MemorySnippetModel{
+		snippets:  make(map[int]*Snippet),
+		revisions: make(map[int][]*Revision),
+		passwords: make(map[int]string),
+		slugs:     make(map[string]int),
+		nextID:    1,
+
+		nextAttachmentID: 1,
+	}
+}
+
+func (m *MemorySnippetModel) Insert(ctx context.Context, n NewSnippet) (int, string, error) {
+	// hashing is slow on purpose, so it's done before taking the lock
+	hash, err := hashPassword(n.Password)
+	if err != nil {
+		return 0, "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slug := ""
+	for attempt := 1; slug == ""; attempt++ {
+		if slug, err = newSlug(m.SlugLength); err != nil {
+			return 0, "", err
+		}
+		if _, taken := m.slugs[slug]; taken {
+			if attempt == maxSlugAttempts {
+				return 0, "", errors.New("models: no free slug")
+			}
+			slug = ""
+		}
+	}
+
+	now := time.Now().UTC()
+	s := &Snippet{
+		ID:       m.nextID,
+		Slug:     slug,
+		Title:    n.Title,
+		Content:  n.Content,
+		Created:  now,
+		Revision: 1,
+		Language: n.Language,
+		Guessed:  n.Guessed,
+		Tags:     slices.Clone(n.Tags),
+		Filename: n.Filename,
+		Files:    slices.Clone(n.Files),
+
+		BurnAfterReading: n.BurnAfterReading,
+		Protected:        hash != "",
+		Visibility:       n.Visibility,
+		Owner:            n.Owner,
+		ForkedFrom:       n.ForkedFrom,
+	}
+	if !n.Expires.IsZero() {
+		s.Expires = n.Expires.UTC().Truncate(time.Minute)
+	}
+	if hash != "" {
+		m.passwords[s.ID] = hash
+	}
+	for _, a := range n.Attachments {
+		a.ID, a.Created = m.nextAttachmentID, now
+		s.Attachments = append(s.Attachments, a)
+		m.nextAttachmentID++
+	}
+	slices.Sort(s.Tags)
+	m.snippets[s.ID] = s
+	m.slugs[slug] = s.ID
+	m.revisions[s.ID] = []*Revision{{SnippetID: s.ID, Number: 1, Title: n.Title, Content: n.Content, Created: now}}
+	m.nextID++
+
+	return s.ID, slug, nil
+}
+
+func (m *MemorySnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, err := m.live(id)
+	if err != nil {
+		return nil, err
+	}
+	// hand out a copy so callers can't mutate the stored snippet
+	c := *s
+	c.Tags = slices.Clone(s.Tags)
+	c.Files = slices.Clone(s.Files)
+	c.Attachments = slices.Clone(s.Attachments)
+	return &c, nil
+}
+
+// live returns the stored snippet with id if it hasn't expired. Callers must
+// hold m.mu.
+func (m *MemorySnippetModel) live(id int) (*Snippet, error) {
+	s, ok := m.snippets[id]
+	if !ok || s.expired(time.Now().UTC()) || !s.Deleted.IsZero() {
+		return nil, constants.ErrNoRecord
+	}
+	return s, nil
+}
+
+// GetBySlug is Get by the snippet's slug instead of its id.
+func (m *MemorySnippetModel) GetBySlug(ctx context.Context, slug string) (*Snippet, error) {
+	m.mu.RLock()
+	id, ok := m.slugs[slug]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, constants.ErrNoRecord
+	}
+	return m.Get(ctx, id)
+}
+
+// remove drops a snippet and everything kept about it. Needs the write lock.
+func (m *MemorySnippetModel) remove(s *Snippet) {
+	delete(m.snippets, s.ID)
+	delete(m.revisions, s.ID)
+	delete(m.passwords, s.ID)
+	delete(m.slugs, s.Slug)
+	for _, a := range s.Attachments {
+		m.orphans = append(m.orphans, a.Key)
+	}
+}
+
+// expired reports whether s has an expiry and it has passed by now.
+func (s *Snippet) expired(now time.Time) bool {
+	return !s.Expires.IsZero() && !s.Expires.After(now)
+}
+
+// returns 10 most recently created snippets
+func (m *MemorySnippetModel) Latest(ctx context.Context) ([]*Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	snippets := []*Snippet{}
+	// ids are handed out sequentially, so walking down from the last one
+	// gives the same order as "ORDER BY id DESC"
+	for id := m.nextID - 1; id > 0 && len(snippets) < 10; id-- {
+		s, ok := m.snippets[id]
+		if !ok || s.expired(now) || !s.Deleted.IsZero() || s.BurnAfterReading || s.Visibility != VisibilityPublic {
+			continue
+		}
+		c := *s
+		snippets = append(snippets, &c)
+	}
+
+	return snippets, nil
+}
+
+// Page walks the ids the same way the sql models do with their keyset query.
+func (m *MemorySnippetModel) Page(ctx context.Context, tag string, before int, after int, size int) ([]*Snippet, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// ids to look at, in the order the sql models would return them
+	ids := []int{}
+	if after > 0 {
+		for id := after + 1; id < m.nextID; id++ {
+			ids = append(ids, id)
+		}
+	} else {
+		start := m.nextID - 1
+		if before > 0 {
+			start = min(before-1, start)
+		}
+		for id := start; id > 0; id-- {
+			ids = append(ids, id)
+		}
+	}
+
+	now := time.Now().UTC()
+	snippets := []*Snippet{}
+	// one extra snippet tells us whether there is another page
+	for _, id := range ids {
+		if len(snippets) > size {
+			break
+		}
+		s, ok := m.snippets[id]
+		if !ok || s.expired(now) || !s.Deleted.IsZero() || s.BurnAfterReading || s.Visibility != VisibilityPublic {
+			continue
+		}
+		if tag != "" && !slices.Contains(s.Tags, tag) {
+			continue
+		}
+		c := *s
+		// list queries in the sql models don't load tags or files either
+		c.Tags = nil
+		c.Files = nil
+		snippets = append(snippets, &c)
+	}
+
+	more := len(snippets) > size
+	if more {
+		snippets = snippets[:size]
+	}
+	if after > 0 {
+		slices.Reverse(snippets)
+	}
+	return snippets, more, nil
+}
+
+// Search scores every live snippet by how often the query words appear, with
+// a title hit worth ten content hits (the same weighting as the SQLite bm25
+// call), and returns the best limit of them.
+func (m *MemorySnippetModel) Search(ctx context.Context, query string, limit int) ([]*Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := SearchTerms(query)
+	now := time.Now().UTC()
+	scores := map[int]int{}
+	snippets := []*Snippet{}
+	for _, s := range m.snippets {
+		if s.expired(now) || !s.Deleted.IsZero() || s.BurnAfterReading || s.Protected || s.Visibility != VisibilityPublic {
+			continue
+		}
+		score := 0
+		for _, t := range terms {
+			score += 10*count(words(s.Title), t) + count(words(s.Content), t)
+		}
+		if score > 0 {
+			c := *s
+			snippets = append(snippets, &c)
+			scores[s.ID] = score
+		}
+	}
+
+	sort.Slice(snippets, func(i, j int) bool {
+		if scores[snippets[i].ID] != scores[snippets[j].ID] {
+			return scores[snippets[i].ID] > scores[snippets[j].ID]
+		}
+		return snippets[i].ID > snippets[j].ID
+	})
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+	return snippets, nil
+}
+
+func count(words []string, term string) int {
+	n := 0
+	for _, w := range words {
+		if w == term {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *MemorySnippetModel) Update(ctx context.Context, id int, title string, content string, language string, guessed bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.live(id)
+	if err != nil {
+		return 0, err
+	}
+	s.Title = title
+	s.Content = content
+	s.Language = language
+	s.Guessed = guessed
+	s.Revision++
+	m.revisions[id] = append(m.revisions[id], &Revision{
+		SnippetID: id,
+		Number:    s.Revision,
+		Title:     title,
+		Content:   content,
+		Created:   time.Now().UTC(),
+	})
+
+	return s.Revision, nil
+}
+
+// Revisions returns every revision of a live snippet, oldest first.
+func (m *MemorySnippetModel) Revisions(ctx context.Context, id int) ([]*Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := []*Revision{}
+	if _, err := m.live(id); err != nil {
+		return revisions, nil
+	}
+	for _, rev := range m.revisions[id] {
+		c := *rev
+		revisions = append(revisions, &c)
+	}
+	return revisions, nil
+}
+
+func (m *MemorySnippetModel) GetRevision(ctx context.Context, id int, revision int) (*Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, err := m.live(id); err != nil {
+		return nil, err
+	}
+	// revisions are numbered from 1 with no gaps
+	revs := m.revisions[id]
+	if revision < 1 || revision > len(revs) {
+		return nil, constants.ErrNoRecord
+	}
+	c := *revs[revision-1]
+	return &c, nil
+}
+
+func (m *MemorySnippetModel) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.live(id)
+	if err != nil {
+		return err
+	}
+	s.Deleted = time.Now().UTC()
+	return nil
+}
+
+// Trash returns the snippets deleted within the last grace period, most
+// recently deleted first, of the snippets owner created. Only the owner may
+// restore a snippet, so nobody else sees it.
+func (m *MemorySnippetModel) Trash(ctx context.Context, grace time.Duration, owner string) ([]*Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snippets := []*Snippet{}
+	for _, s := range m.snippets {
+		if m.restorable(s, grace) && owner != "" && s.Owner == owner {
+			c := *s
+			snippets = append(snippets, &c)
+		}
+	}
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].Deleted.After(snippets[j].Deleted) })
+	return snippets, nil
+}
+
+func (m *MemorySnippetModel) Restore(ctx context.Context, slug string, grace time.Duration, owner string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[m.slugs[slug]]
+	if !ok || !m.restorable(s, grace) {
+		return 0, constants.ErrNoRecord
+	}
+	if owner == "" || s.Owner != owner {
+		return 0, restoreError(s.Visibility, nil)
+	}
+	s.Deleted = time.Time{}
+	return s.ID, nil
+}
+
+// PurgeDeleted drops snippets that have been in the trash for longer than
+// grace, along with their revisions.
+func (m *MemorySnippetModel) PurgeDeleted(ctx context.Context, grace time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := time.Now().UTC().Add(-grace)
+	n := 0
+	for _, s := range m.snippets {
+		if !s.Deleted.IsZero() && !s.Deleted.After(cutoff) {
+			m.remove(s)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PurgeExpired permanently removes up to limit expired snippets, the ones that
+// expired first, along with their revisions and tags. It returns how many were
+// removed, fewer than limit means there are none left.
+func (m *MemorySnippetModel) PurgeExpired(ctx context.Context, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	expired := []*Snippet{}
+	for _, s := range m.snippets {
+		if s.expired(now) {
+			expired = append(expired, s)
+		}
+	}
+	slices.SortFunc(expired, func(a, b *Snippet) int { return a.Expires.Compare(b.Expires) })
+
+	n := 0
+	for _, s := range expired[:min(limit, len(expired))] {
+		m.remove(s)
+		n++
+	}
+	return n, nil
+}
+
+// restorable reports whether s is in the trash and still inside the grace
+// period. Callers must hold m.mu.
+func (m *MemorySnippetModel) restorable(s *Snippet, grace time.Duration) bool {
+	now := time.Now().UTC()
+	return !s.Deleted.IsZero() && s.Deleted.After(now.Add(-grace)) && !s.expired(now)
+}
+
+// Burn deletes a burn after reading snippet and returns it as it was. Of any
+// number of concurrent callers only one gets the snippet, the rest get
+// ErrNoRecord. Tags aren't loaded.
+func (m *MemorySnippetModel) Burn(ctx context.Context, id int) (*Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.live(id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.BurnAfterReading {
+		return nil, constants.ErrNoRecord
+	}
+	m.remove(s)
+
+	c := *s
+	c.Tags = nil
+	c.Files = nil
+	return &c, nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password is the one the
+// snippet was created with. Snippets without a password accept anything.
+func (m *MemorySnippetModel) CheckPassword(ctx context.Context, id int, password string) error {
+	m.mu.RLock()
+	_, err := m.live(id)
+	hash := m.passwords[id]
+	m.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+	return checkPassword(hash, password)
+}
+
+// Forks returns the live, listed snippets forked from a snippet, newest first.
+func (m *MemorySnippetModel) Forks(ctx context.Context, id int) ([]*Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now().UTC()
+	snippets := []*Snippet{}
+	for i := m.nextID - 1; i > id; i-- {
+		s, ok := m.snippets[i]
+		if !ok || s.ForkedFrom != id || s.expired(now) || !s.Deleted.IsZero() || s.BurnAfterReading || s.Visibility != VisibilityPublic {
+			continue
+		}
+		snippets = append(snippets, &Snippet{ID: s.ID, Slug: s.Slug, Title: s.Title, Created: s.Created})
+	}
+
+	return snippets, nil
+}
+
+func (m *MemorySnippetModel) OrphanedBlobs(ctx context.Context, limit int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.orphans[:min(limit, len(m.orphans))]), nil
+}
+
+func (m *MemorySnippetModel) DeleteBlobs(ctx context.Context, keys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.orphans = slices.DeleteFunc(m.orphans, func(key string) bool { return slices.Contains(keys, key) })
+	return nil
+}
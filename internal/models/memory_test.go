@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"snippetbox.tushar.net/internal/constants"
+)
+
+func TestMemoryBurnConcurrent(t *testing.T) {
+	m := NewMemorySnippetModel()
+	ctx := context.Background()
+
+	id, _, err := m.Insert(ctx, NewSnippet{
+		Title:            "Secret",
+		Content:          "only once",
+		BurnAfterReading: true,
+		Visibility:       VisibilityPublic,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const viewers = 50
+	var wg sync.WaitGroup
+	results := make(chan *Snippet, viewers)
+	errs := make(chan error, viewers)
+	start := make(chan struct{})
+	for range viewers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			s, err := m.Burn(ctx, id)
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- s
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	if len(results) != 1 {
+		t.Fatalf("%d of %d callers got the snippet; want 1", len(results), viewers)
+	}
+	if s := <-results; s.Content != "only once" {
+		t.Errorf("got content %q; want %q", s.Content, "only once")
+	}
+	for err := range errs {
+		if !errors.Is(err, constants.ErrNoRecord) {
+			t.Errorf("got %v; want ErrNoRecord", err)
+		}
+	}
+	if _, err := m.Get(ctx, id); !errors.Is(err, constants.ErrNoRecord) {
+		t.Errorf("Get after Burn: got %v; want ErrNoRecord", err)
+	}
+}
+
+func TestMemoryBurnNotBurnAfterReading(t *testing.T) {
+	m := NewMemorySnippetModel()
+	ctx := context.Background()
+
+	id, _, err := m.Insert(ctx, NewSnippet{Title: "Kept", Content: "stays", Visibility: VisibilityPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Burn(ctx, id); !errors.Is(err, constants.ErrNoRecord) {
+		t.Errorf("got %v; want ErrNoRecord", err)
+	}
+	if _, err := m.Get(ctx, id); err != nil {
+		t.Errorf("Get after a refused Burn: %s", err)
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+	"snippetbox.tushar.net/internal/constants"
+)
+
+// hashPassword returns the bcrypt hash stored for a snippet password, or an
+// empty string when the snippet isn't protected.
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword compares password with a stored hash. A snippet without a
+// password accepts anything.
+func checkPassword(hash string, password string) error {
+	if hash == "" {
+		return nil
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return constants.ErrInvalidCredentials
+	}
+	return err
+}
@@ -0,0 +1,720 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"snippetbox.tushar.net/internal/blobs"
+	"snippetbox.tushar.net/internal/constants"
+)
+
+// PostgresSnippetModel is the PostgreSQL flavour of SnippetModel. Postgres uses
+// $n placeholders, has no LastInsertId (the new id comes back with RETURNING)
+// and does its date arithmetic with now() + interval.
+type PostgresSnippetModel struct {
+	DB         *sql.DB
+	SlugLength int // length of new slugs, DefaultSlugLength if 0
+
+	// Content and files longer than ContentThreshold bytes go to Blobs, the
+	// row keeps their first ContentThreshold bytes, for search, and the key.
+	// 0 keeps all content in the database
+	Blobs            blobs.Store
+	ContentThreshold int
+}
+
+// Insert stores a new snippet under a random slug and returns its id and slug.
+// A slug that's already taken is retried with a fresh one.
+func (m *PostgresSnippetModel) Insert(ctx context.Context, n NewSnippet) (int, string, error) {
+
+	// hashing is slow on purpose, so it's done before the transaction starts
+	hash, err := hashPassword(n.Password)
+	if err != nil {
+		return 0, "", err
+	}
+	content, contentKey, err := offloadContent(ctx, m.Blobs, m.ContentThreshold, n.Content)
+	if err != nil {
+		return 0, "", err
+	}
+	files, fileKeys, err := offloadFiles(ctx, m.Blobs, m.ContentThreshold, n.Files)
+	if err != nil {
+		discardContent(ctx, m.Blobs, contentKey)
+		return 0, "", err
+	}
+	n.Content, n.Files = content, files
+	keys := append([]string{contentKey}, fileKeys...)
+
+	for attempt := 1; ; attempt++ {
+		slug, err := newSlug(m.SlugLength)
+		if err != nil {
+			discardContent(ctx, m.Blobs, keys...)
+			return 0, "", err
+		}
+		id, err := m.insert(ctx, n, hash, slug, contentKey, fileKeys)
+		if err != nil {
+			if isDuplicateSlug(err) && attempt < maxSlugAttempts {
+				continue
+			}
+			discardContent(ctx, m.Blobs, keys...)
+			return 0, "", err
+		}
+		return id, slug, nil
+	}
+}
+
+func (m *PostgresSnippetModel) insert(ctx context.Context, n NewSnippet, hash string, slug string, contentKey string, fileKeys []string) (int, error) {
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	stmt := `insert into snippets (slug, title, content, language, language_guessed, burn_after_reading, password_hash, visibility, owner, created, expires, filename, forked_from, content_key)
+	values($1, $2, $3, $4, $5, $6, $7, $8, $9, now(), $10, $11, $12, $13) returning id`
+	err = tx.QueryRowContext(ctx, stmt, slug, n.Title, n.Content, n.Language, n.Guessed, n.BurnAfterReading, hash, n.Visibility, n.Owner, expiresArg(n.Expires), n.Filename, nullID(n.ForkedFrom), contentKey).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `insert into snippet_revisions (snippet_id, revision, title, content, content_key, created)
+	select id, revision, title, content, content_key, created from snippets where id = $1`
+	if _, err = tx.ExecContext(ctx, stmt, id); err != nil {
+		return 0, err
+	}
+
+	// every offloaded blob is tracked, so the reaper finds it once the snippet
+	// is purged
+	for _, key := range append([]string{contentKey}, fileKeys...) {
+		if key == "" {
+			continue
+		}
+		stmt = `insert into snippet_content_blobs (blob_key, snippet_id) values($1, $2)`
+		if _, err = tx.ExecContext(ctx, stmt, key, id); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, tag := range n.Tags {
+		if _, err = tx.ExecContext(ctx, `insert into tags (name) values($1) on conflict (name) do nothing`, tag); err != nil {
+			return 0, err
+		}
+		stmt = `insert into snippet_tags (snippet_id, tag_id) select $1, id from tags where name = $2`
+		if _, err = tx.ExecContext(ctx, stmt, id, tag); err != nil {
+			return 0, err
+		}
+	}
+
+	// the first file lives in the snippet itself, the rest are numbered from 1
+	for i, file := range n.Files {
+		stmt = `insert into snippet_files (snippet_id, position, name, language, content, content_key) values($1, $2, $3, $4, $5, $6)`
+		if _, err = tx.ExecContext(ctx, stmt, id, i+1, file.Name, file.Language, file.Content, fileKeys[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, a := range n.Attachments {
+		stmt = `insert into snippet_attachments (snippet_id, name, content_type, size, blob_key, created) values($1, $2, $3, $4, $5, now())`
+		if _, err = tx.ExecContext(ctx, stmt, id, a.Name, a.ContentType, a.Size, a.Key); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (m *PostgresSnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	return m.get(ctx, "id", id)
+}
+
+// GetBySlug is Get by the snippet's slug instead of its id.
+func (m *PostgresSnippetModel) GetBySlug(ctx context.Context, slug string) (*Snippet, error) {
+	return m.get(ctx, "slug", slug)
+}
+
+// get loads a live snippet and its tags by column, either id or slug.
+func (m *PostgresSnippetModel) get(ctx context.Context, column string, value any) (*Snippet, error) {
+
+	s := &Snippet{}
+	var contentKey string
+	stmt := `select id, slug, title, content, language, language_guessed, burn_after_reading, password_hash <> '', visibility, owner, created, expires, revision, filename, coalesce(forked_from, 0), content_key
+	from snippets where (expires is null or expires > now()) and deleted_at is null and ` + column + ` = $1`
+	err := m.DB.QueryRowContext(ctx, stmt, value).Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Language, &s.Guessed, &s.BurnAfterReading, &s.Protected, &s.Visibility, &s.Owner, &s.Created, nullTime{&s.Expires}, &s.Revision, &s.Filename, &s.ForkedFrom, &contentKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, constants.ErrNoRecord
+		}
+		return nil, err
+	}
+	s.Content, err = loadContent(ctx, m.Blobs, s.Content, contentKey)
+	if err != nil {
+		return nil, err
+	}
+	s.Tags, err = m.tags(ctx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.Files, err = m.files(ctx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.Attachments, err = m.attachments(ctx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// tags returns the names of a snippet's tags in alphabetical order.
+func (m *PostgresSnippetModel) tags(ctx context.Context, id int) ([]string, error) {
+
+	tags := []string{}
+	stmt := `select t.name from tags t join snippet_tags st on st.tag_id = t.id
+	where st.snippet_id = $1 order by t.name`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// files returns a snippet's files after the first one, in order.
+func (m *PostgresSnippetModel) files(ctx context.Context, id int) ([]File, error) {
+
+	files := []File{}
+	keys := []string{}
+	stmt := `select name, language, content, content_key from snippet_files where snippet_id = $1 order by position`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f File
+		var key string
+		if err := rows.Scan(&f.Name, &f.Language, &f.Content, &key); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		files[i].Content, err = loadContent(ctx, m.Blobs, files[i].Content, keys[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// attachments returns the files uploaded with a snippet, in upload order.
+func (m *PostgresSnippetModel) attachments(ctx context.Context, id int) ([]Attachment, error) {
+
+	attachments := []Attachment{}
+	stmt := `select id, name, content_type, size, blob_key, created from snippet_attachments where snippet_id = $1 order by id`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.ID, &a.Name, &a.ContentType, &a.Size, &a.Key, &a.Created); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// returns 10 most recently created snippets
+func (m *PostgresSnippetModel) Latest(ctx context.Context) ([]*Snippet, error) {
+
+	snippets := []*Snippet{}
+	stmt := `select id, slug, title, content, created, expires, revision from snippets
+	where (expires is null or expires > now()) and deleted_at is null and not burn_after_reading and visibility = 'public' order by id desc limit 10`
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Created, nullTime{&s.Expires}, &s.Revision)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Page returns up to size live snippets, newest first, using the id as a
+// keyset cursor. With before > 0 only snippets older than that id are
+// returned, with after > 0 only newer ones. A non-empty tag limits the page to
+// snippets with that tag. more reports whether there are further snippets
+// past the page in the direction being paged.
+func (m *PostgresSnippetModel) Page(ctx context.Context, tag string, before int, after int, size int) ([]*Snippet, bool, error) {
+
+	stmt := `select id, slug, title, content, created, expires, revision from snippets
+	where (expires is null or expires > now()) and deleted_at is null and not burn_after_reading and visibility = 'public' and id < $1
+	and ($3 = '' or id in (select st.snippet_id from snippet_tags st join tags t on t.id = st.tag_id where t.name = $3))
+	order by id desc limit $2`
+	cursor := before
+	if after > 0 {
+		// walk forwards from the cursor, the rows get flipped back below
+		stmt = `select id, slug, title, content, created, expires, revision from snippets
+		where (expires is null or expires > now()) and deleted_at is null and not burn_after_reading and visibility = 'public' and id > $1
+		and ($3 = '' or id in (select st.snippet_id from snippet_tags st join tags t on t.id = st.tag_id where t.name = $3))
+		order by id asc limit $2`
+		cursor = after
+	} else if before <= 0 {
+		cursor = math.MaxInt32
+	}
+
+	// one extra row tells us whether there is another page
+	rows, err := m.DB.QueryContext(ctx, stmt, cursor, size+1, tag)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+	for rows.Next() {
+		s := &Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Created, nullTime{&s.Expires}, &s.Revision)
+		if err != nil {
+			return nil, false, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	more := len(snippets) > size
+	if more {
+		snippets = snippets[:size]
+	}
+	if after > 0 {
+		slices.Reverse(snippets)
+	}
+	return snippets, more, nil
+}
+
+// Search returns up to limit live snippets matching any word of query, best
+// match first. Titles are weighted A and content B, so ts_rank puts title
+// matches first. The vector expression must match idx_snippets_search.
+func (m *PostgresSnippetModel) Search(ctx context.Context, query string, limit int) ([]*Snippet, error) {
+
+	snippets := []*Snippet{}
+	terms := strings.Join(SearchTerms(query), " | ")
+	if terms == "" {
+		return snippets, nil
+	}
+
+	stmt := `select id, slug, title, content, created, expires, revision
+	from snippets, to_tsquery('simple', $1) q
+	where (setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B')) @@ q
+	and (expires is null or expires > now()) and deleted_at is null and not burn_after_reading and password_hash = '' and visibility = 'public'
+	order by ts_rank(setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B'), q) desc,
+	id desc limit $2`
+	rows, err := m.DB.QueryContext(ctx, stmt, terms, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Created, nullTime{&s.Expires}, &s.Revision)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+func (m *PostgresSnippetModel) Update(ctx context.Context, id int, title string, content string, language string, guessed bool) (int, error) {
+
+	content, contentKey, err := offloadContent(ctx, m.Blobs, m.ContentThreshold, content)
+	if err != nil {
+		return 0, err
+	}
+	revision, err := m.update(ctx, id, title, content, contentKey, language, guessed)
+	if err != nil {
+		discardContent(ctx, m.Blobs, contentKey)
+		return 0, err
+	}
+	return revision, nil
+}
+
+// update is Update once the content is where it belongs.
+func (m *PostgresSnippetModel) update(ctx context.Context, id int, title string, content string, contentKey string, language string, guessed bool) (int, error) {
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var revision int
+	stmt := `update snippets set title = $1, content = $2, content_key = $3, language = $4, language_guessed = $5, revision = revision + 1
+	where (expires is null or expires > now()) and deleted_at is null and id = $6 returning revision`
+	err = tx.QueryRowContext(ctx, stmt, title, content, contentKey, language, guessed, id).Scan(&revision)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, constants.ErrNoRecord
+		}
+		return 0, err
+	}
+
+	stmt = `insert into snippet_revisions (snippet_id, revision, title, content, content_key, created)
+	values($1, $2, $3, $4, $5, now())`
+	if _, err = tx.ExecContext(ctx, stmt, id, revision, title, content, contentKey); err != nil {
+		return 0, err
+	}
+
+	if contentKey != "" {
+		stmt = `insert into snippet_content_blobs (blob_key, snippet_id) values($1, $2)`
+		if _, err = tx.ExecContext(ctx, stmt, contentKey, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// Revisions returns every revision of a live snippet, oldest first. Content
+// that was moved to blob storage is left empty, GetRevision loads it.
+func (m *PostgresSnippetModel) Revisions(ctx context.Context, id int) ([]*Revision, error) {
+
+	revisions := []*Revision{}
+	stmt := `select r.snippet_id, r.revision, r.title, r.content, r.created
+	from snippet_revisions r join snippets s on s.id = r.snippet_id
+	where (s.expires is null or s.expires > now()) and s.deleted_at is null and r.snippet_id = $1 order by r.revision`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rev := &Revision{}
+		err := rows.Scan(&rev.SnippetID, &rev.Number, &rev.Title, &rev.Content, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (m *PostgresSnippetModel) GetRevision(ctx context.Context, id int, revision int) (*Revision, error) {
+
+	rev := &Revision{}
+	var contentKey string
+	stmt := `select r.snippet_id, r.revision, r.title, r.content, r.content_key, r.created
+	from snippet_revisions r join snippets s on s.id = r.snippet_id
+	where (s.expires is null or s.expires > now()) and s.deleted_at is null and r.snippet_id = $1 and r.revision = $2`
+	err := m.DB.QueryRowContext(ctx, stmt, id, revision).Scan(&rev.SnippetID, &rev.Number, &rev.Title, &rev.Content, &contentKey, &rev.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, constants.ErrNoRecord
+		}
+		return nil, err
+	}
+	rev.Content, err = loadContent(ctx, m.Blobs, rev.Content, contentKey)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// Delete moves a live snippet to the trash. It stays restorable until the
+// grace period passed to Restore runs out.
+func (m *PostgresSnippetModel) Delete(ctx context.Context, id int) error {
+
+	stmt := `update snippets set deleted_at = now()
+	where (expires is null or expires > now()) and deleted_at is null and id = $1`
+	r, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return constants.ErrNoRecord
+	}
+	return nil
+}
+
+// Trash returns the snippets deleted within the last grace period that can
+// still be restored, most recently deleted first, of the snippets owner
+// created. Only the owner may restore a snippet, so nobody else sees it.
+func (m *PostgresSnippetModel) Trash(ctx context.Context, grace time.Duration, owner string) ([]*Snippet, error) {
+
+	snippets := []*Snippet{}
+	stmt := `select id, slug, title, content, created, expires, revision, deleted_at from snippets
+	where (expires is null or expires > now()) and deleted_at > now() - $1 * interval '1 second'
+	and owner <> '' and owner = $2 order by deleted_at desc`
+	rows, err := m.DB.QueryContext(ctx, stmt, grace.Seconds(), owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Created, nullTime{&s.Expires}, &s.Revision, &s.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Restore takes the snippet with slug back out of the trash and returns its
+// id, as long as it was deleted within the last grace period and is one that
+// Trash lists for owner. Someone else's snippet is ErrNotOwner, unless it's
+// private, which is ErrNoRecord like a missing one.
+func (m *PostgresSnippetModel) Restore(ctx context.Context, slug string, grace time.Duration, owner string) (int, error) {
+
+	stmt := `update snippets set deleted_at = null
+	where (expires is null or expires > now()) and deleted_at > now() - $1 * interval '1 second'
+	and owner <> '' and owner = $2 and slug = $3 returning id`
+	var id int
+	err := m.DB.QueryRowContext(ctx, stmt, grace.Seconds(), owner, slug).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, m.notRestored(ctx, slug, grace)
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// notRestored tells why Restore found nothing to restore.
+func (m *PostgresSnippetModel) notRestored(ctx context.Context, slug string, grace time.Duration) error {
+
+	stmt := `select visibility from snippets
+	where (expires is null or expires > now()) and deleted_at > now() - $1 * interval '1 second' and slug = $2`
+	var visibility string
+	err := m.DB.QueryRowContext(ctx, stmt, grace.Seconds(), slug).Scan(&visibility)
+	return restoreError(visibility, err)
+}
+
+// PurgeDeleted removes snippets that have been in the trash for longer than
+// grace, along with their revisions, and returns how many were removed.
+func (m *PostgresSnippetModel) PurgeDeleted(ctx context.Context, grace time.Duration) (int, error) {
+
+	stmt := `delete from snippets where deleted_at <= now() - $1 * interval '1 second'`
+	r, err := m.DB.ExecContext(ctx, stmt, grace.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// PurgeExpired permanently removes up to limit expired snippets, the ones that
+// expired first, along with their revisions and tags. It returns how many were
+// removed, fewer than limit means there are none left.
+func (m *PostgresSnippetModel) PurgeExpired(ctx context.Context, limit int) (int, error) {
+
+	stmt := `delete from snippets where id in (
+		select id from snippets where expires <= now() order by expires limit $1)`
+	r, err := m.DB.ExecContext(ctx, stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// Burn deletes a burn after reading snippet and returns it as it was. Of any
+// number of concurrent callers only one gets the snippet, the rest get
+// ErrNoRecord. Tags aren't loaded.
+func (m *PostgresSnippetModel) Burn(ctx context.Context, id int) (*Snippet, error) {
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s := &Snippet{}
+	var contentKey string
+	stmt := `delete from snippets
+	where (expires is null or expires > now()) and deleted_at is null and burn_after_reading and id = $1
+	returning id, slug, title, content, language, language_guessed, burn_after_reading, visibility, created, expires, revision, filename, content_key`
+	err = tx.QueryRowContext(ctx, stmt, id).Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Language, &s.Guessed, &s.BurnAfterReading, &s.Visibility, &s.Created, nullTime{&s.Expires}, &s.Revision, &s.Filename, &contentKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, constants.ErrNoRecord
+		}
+		return nil, err
+	}
+	// offloaded content is read before the delete commits: if that fails the
+	// snippet is still there, and the reaper only sees the blob once it's read
+	s.Content, err = loadContent(ctx, m.Blobs, s.Content, contentKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password is the one the
+// snippet was created with. Snippets without a password accept anything.
+func (m *PostgresSnippetModel) CheckPassword(ctx context.Context, id int, password string) error {
+
+	var hash string
+	stmt := `select password_hash from snippets where (expires is null or expires > now()) and deleted_at is null and id = $1`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return constants.ErrNoRecord
+		}
+		return err
+	}
+	return checkPassword(hash, password)
+}
+
+// Forks returns the live, listed snippets forked from a snippet, newest first.
+func (m *PostgresSnippetModel) Forks(ctx context.Context, id int) ([]*Snippet, error) {
+
+	snippets := []*Snippet{}
+	stmt := `select id, slug, title, created from snippets
+	where (expires is null or expires > now()) and deleted_at is null and not burn_after_reading and visibility = 'public' and forked_from = $1 order by id desc`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &Snippet{}
+		if err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Created); err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// OrphanedBlobs returns up to limit keys of blobs, attachments and offloaded
+// content, whose snippet has been purged. Once the blobs are deleted their
+// rows go with DeleteBlobs.
+func (m *PostgresSnippetModel) OrphanedBlobs(ctx context.Context, limit int) ([]string, error) {
+
+	keys := []string{}
+	stmt := `select blob_key from snippet_attachments where snippet_id is null
+	union all select blob_key from snippet_content_blobs where snippet_id is null limit $1`
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// DeleteBlobs removes the rows of orphaned blobs by key.
+func (m *PostgresSnippetModel) DeleteBlobs(ctx context.Context, keys []string) error {
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, key := range keys {
+		if _, err := tx.ExecContext(ctx, `delete from snippet_attachments where snippet_id is null and blob_key = $1`, key); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `delete from snippet_content_blobs where snippet_id is null and blob_key = $1`, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+)
+
+// Replica is a read replica of the primary database.
+type Replica struct {
+	Name  string       // for logs, the replica's host
+	Store SnippetStore // queries the replica
+	DB    *sql.DB      // pinged by Check
+
+	down atomic.Bool
+}
+
+// Check pings the replica and takes it out of rotation while that fails. It
+// reports whether that changed the replica's state.
+func (r *Replica) Check(ctx context.Context) (changed bool, err error) {
+	err = r.DB.PingContext(ctx)
+	return r.down.Swap(err != nil) != (err != nil), err
+}
+
+// Up reports whether the replica passed its last Check.
+func (r *Replica) Up() bool {
+	return !r.down.Load()
+}
+
+type readPrimaryKey struct{}
+
+// ReadPrimary marks ctx so that ReplicaStore reads from the primary, for a
+// session that has just written something and expects to see it before the
+// replicas catch up. CachedStore doesn't serve such reads from the cache
+// either, a copy from a lagging replica may be in it.
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey{}, true)
+}
+
+func readsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(readPrimaryKey{}).(bool)
+	return primary
+}
+
+// ReplicaStore sends the reads that can live with replication lag to the
+// replicas that are up, round-robin, and everything else to the primary.
+// The primary takes the reads as well when no replica is up or ctx is
+// marked by ReadPrimary. Writes, the trash, passwords and the reaper's
+// queries always go to the primary.
+type ReplicaStore struct {
+	Primary  SnippetStore
+	Replicas []*Replica
+
+	next atomic.Uint64
+}
+
+func (s *ReplicaStore) reader(ctx context.Context) SnippetStore {
+	if readsPrimary(ctx) {
+		return s.Primary
+	}
+	n := uint64(len(s.Replicas))
+	start := s.next.Add(1)
+	for i := range n {
+		if r := s.Replicas[(start+i)%n]; r.Up() {
+			return r.Store
+		}
+	}
+	return s.Primary
+}
+
+func (s *ReplicaStore) Get(ctx context.Context, id int) (*Snippet, error) {
+	return s.reader(ctx).Get(ctx, id)
+}
+
+func (s *ReplicaStore) GetBySlug(ctx context.Context, slug string) (*Snippet, error) {
+	return s.reader(ctx).GetBySlug(ctx, slug)
+}
+
+func (s *ReplicaStore) Latest(ctx context.Context) ([]*Snippet, error) {
+	return s.reader(ctx).Latest(ctx)
+}
+
+func (s *ReplicaStore) Page(ctx context.Context, tag string, before int, after int, size int) ([]*Snippet, bool, error) {
+	return s.reader(ctx).Page(ctx, tag, before, after, size)
+}
+
+func (s *ReplicaStore) Search(ctx context.Context, query string, limit int) ([]*Snippet, error) {
+	return s.reader(ctx).Search(ctx, query, limit)
+}
+
+func (s *ReplicaStore) Revisions(ctx context.Context, id int) ([]*Revision, error) {
+	return s.reader(ctx).Revisions(ctx, id)
+}
+
+func (s *ReplicaStore) GetRevision(ctx context.Context, id int, revision int) (*Revision, error) {
+	return s.reader(ctx).GetRevision(ctx, id, revision)
+}
+
+func (s *ReplicaStore) Forks(ctx context.Context, id int) ([]*Snippet, error) {
+	return s.reader(ctx).Forks(ctx, id)
+}
+
+func (s *ReplicaStore) Insert(ctx context.Context, n NewSnippet) (int, string, error) {
+	return s.Primary.Insert(ctx, n)
+}
+
+func (s *ReplicaStore) Update(ctx context.Context, id int, title string, content string, language string, guessed bool) (int, error) {
+	return s.Primary.Update(ctx, id, title, content, language, guessed)
+}
+
+func (s *ReplicaStore) Delete(ctx context.Context, id int) error {
+	return s.Primary.Delete(ctx, id)
+}
+
+func (s *ReplicaStore) Trash(ctx context.Context, grace time.Duration, owner string) ([]*Snippet, error) {
+	return s.Primary.Trash(ctx, grace, owner)
+}
+
+func (s *ReplicaStore) Restore(ctx context.Context, slug string, grace time.Duration, owner string) (int, error) {
+	return s.Primary.Restore(ctx, slug, grace, owner)
+}
+
+func (s *ReplicaStore) PurgeDeleted(ctx context.Context, grace time.Duration) (int, error) {
+	return s.Primary.PurgeDeleted(ctx, grace)
+}
+
+func (s *ReplicaStore) PurgeExpired(ctx context.Context, limit int) (int, error) {
+	return s.Primary.PurgeExpired(ctx, limit)
+}
+
+func (s *ReplicaStore) Burn(ctx context.Context, id int) (*Snippet, error) {
+	return s.Primary.Burn(ctx, id)
+}
+
+func (s *ReplicaStore) CheckPassword(ctx context.Context, id int, password string) error {
+	return s.Primary.CheckPassword(ctx, id, password)
+}
+
+func (s *ReplicaStore) OrphanedBlobs(ctx context.Context, limit int) ([]string, error) {
+	return s.Primary.OrphanedBlobs(ctx, limit)
+}
+
+func (s *ReplicaStore) DeleteBlobs(ctx context.Context, keys []string) error {
+	return s.Primary.DeleteBlobs(ctx, keys)
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.tushar.net/internal/constants"
+)
+
+// newTestReplicaStore returns a ReplicaStore over two unrelated memory
+// stores, so what a call returns tells which one it went to: a replica that
+// never catches up.
+func newTestReplicaStore() (s *ReplicaStore, primary *MemorySnippetModel, replica *MemorySnippetModel) {
+	primary, replica = NewMemorySnippetModel(), NewMemorySnippetModel()
+	return &ReplicaStore{Primary: primary, Replicas: []*Replica{{Name: "replica 1", Store: replica}}}, primary, replica
+}
+
+func TestReplicaStoreReads(t *testing.T) {
+	s, primary, replica := newTestReplicaStore()
+	ctx := context.Background()
+
+	onPrimary, _, err := primary.Insert(ctx, NewSnippet{Title: "An old silent pond", Content: "An old silent pond...", Visibility: VisibilityPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	onReplica, replicaSlug, err := replica.Insert(ctx, NewSnippet{Title: "A frog jumps", Content: "A frog jumps into the pond", Visibility: VisibilityPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onPrimary != onReplica {
+		t.Fatalf("got ids %d and %d; want the same id in both stores", onPrimary, onReplica)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"Replica", ctx, "A frog jumps"},
+		{"Read primary", ReadPrimary(ctx), "An old silent pond"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Get(tt.ctx, onPrimary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != tt.want {
+				t.Errorf("Get: got %q; want %q", got.Title, tt.want)
+			}
+			latest, err := s.Latest(tt.ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(latest) != 1 || latest[0].Title != tt.want {
+				t.Errorf("Latest: got %d snippets; want %q", len(latest), tt.want)
+			}
+		})
+	}
+
+	t.Run("By slug", func(t *testing.T) {
+		if _, err := s.GetBySlug(ctx, replicaSlug); err != nil {
+			t.Errorf("got %v; want the replica's snippet", err)
+		}
+		if _, err := s.GetBySlug(ReadPrimary(ctx), replicaSlug); !errors.Is(err, constants.ErrNoRecord) {
+			t.Errorf("read primary: got %v; want %v", err, constants.ErrNoRecord)
+		}
+	})
+}
+
+func TestReplicaStoreWrites(t *testing.T) {
+	s, primary, replica := newTestReplicaStore()
+	ctx := context.Background()
+
+	id, slug, err := s.Insert(ctx, NewSnippet{Title: "An old silent pond", Content: "An old silent pond...", Visibility: VisibilityPublic, Owner: "owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replica.Get(ctx, id); !errors.Is(err, constants.ErrNoRecord) {
+		t.Fatalf("insert reached the replica: got %v; want %v", err, constants.ErrNoRecord)
+	}
+
+	if _, err := s.Update(ctx, id, "A frog jumps", "A frog jumps into the pond", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := primary.Get(ctx, id); err != nil || got.Title != "A frog jumps" {
+		t.Fatalf("update: got %v, %v; want the new title on the primary", got, err)
+	}
+
+	if err := s.Delete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	trash, err := s.Trash(ctx, time.Hour, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 {
+		t.Fatalf("got %d snippets in the trash; want the deleted one", len(trash))
+	}
+	if _, err := s.Restore(ctx, slug, time.Hour, "owner"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := primary.Get(ctx, id); err != nil {
+		t.Fatalf("restore: got %v; want the snippet back on the primary", err)
+	}
+
+	burnID, _, err := s.Insert(ctx, NewSnippet{Title: "Secret", Content: "only once", BurnAfterReading: true, Visibility: VisibilityPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Burn(ctx, burnID); err != nil {
+		t.Errorf("burn: got %v; want the primary's snippet", err)
+	}
+}
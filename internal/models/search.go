@@ -0,0 +1,36 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxSearchTerms caps how many words of a query are used, so a pasted wall of
+// text doesn't turn into a huge fulltext query.
+const maxSearchTerms = 10
+
+// SearchTerms splits a search query into lower-cased words of letters and
+// digits, dropping duplicates. Every backend builds its fulltext query from
+// these terms (matching any of them), and the handlers use them to highlight
+// matches, so punctuation in the query can never be read as search syntax.
+func SearchTerms(query string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, word := range words(query) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
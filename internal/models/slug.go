@@ -0,0 +1,60 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	// DefaultSlugLength is used when a model's SlugLength isn't set. 62^10 is
+	// about 8e17, far too many to walk.
+	DefaultSlugLength = 10
+
+	// how often Insert picks a new slug after one turned out to be taken
+	maxSlugAttempts = 5
+
+	slugAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// newSlug returns a random URL-safe slug of length n, or DefaultSlugLength
+// if n isn't positive.
+func newSlug(n int) (string, error) {
+	if n <= 0 {
+		n = DefaultSlugLength
+	}
+	max := big.NewInt(int64(len(slugAlphabet)))
+	b := make([]byte, n)
+	for i := range b {
+		r, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = slugAlphabet[r.Int64()]
+	}
+	return string(b), nil
+}
+
+// isDuplicateSlug reports whether err is a unique index violation on the slug
+// column, in any of the supported databases.
+func isDuplicateSlug(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, "idx_snippets_slug")
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" && pqErr.Constraint == "idx_snippets_slug"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteErr.Error(), "snippets.slug")
+	}
+	return false
+}
@@ -1,32 +1,256 @@
 package models
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"math"
+	"slices"
+	"strings"
 	"time"
 
+	"snippetbox.tushar.net/internal/blobs"
 	"snippetbox.tushar.net/internal/constants"
 )
 
 // db entity
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID       int
+	Slug     string // random, used in urls so ids can't be walked
+	Title    string
+	Content  string
+	Created  time.Time
+	Expires  time.Time // zero for snippets that never expire
+	Revision int       // number of the latest revision, Title and Content are taken from it
+	Deleted  time.Time // when the snippet was moved to the trash, zero while it's live
+	Tags     []string  // only loaded by Get
+	Language string    // lexer name used for highlighting, empty for plain text; only loaded by Get
+	Guessed  bool      // Language was detected rather than picked by the author
+
+	// BurnAfterReading snippets are deleted by Burn the first time they're
+	// viewed and left out of listings and search
+	BurnAfterReading bool
+	// Protected snippets have a password, see CheckPassword; they are left
+	// out of search. Only loaded by Get
+	Protected bool
+	// Visibility is one of the Visibility constants, only public snippets
+	// are listed. Owner is the token of the session that created it, only
+	// loaded by Get
+	Visibility string
+	Owner      string
+	// Filename names the snippet's content, which is its first file. Files
+	// holds the files after that one, only loaded by Get; see AllFiles
+	Filename string
+	Files    []File
+	// ForkedFrom is the id of the snippet this one was copied from, 0 for an
+	// original. Only loaded by Get
+	ForkedFrom int
+	// Attachments are uploaded files, only loaded by Get
+	Attachments []Attachment
+}
+
+// File is one named file of a multi-file snippet.
+type File struct {
+	Name     string
+	Language string // lexer name, like Snippet.Language
+	Content  string
+}
+
+// Attachment is a file uploaded with a snippet. Its bytes are kept in blob
+// storage under Key, the database only has what's needed to serve them.
+type Attachment struct {
+	ID          int
+	Name        string // base name of the uploaded file
+	ContentType string // sniffed from the first bytes on upload
+	Size        int64
+	Key         string // blob key
+	Created     time.Time
+}
+
+// OwnedBy reports whether token, a session's owner token, is the snippet's
+// owner. Snippets from CachedStore only have a hash of the owner's token.
+func (s *Snippet) OwnedBy(token string) bool {
+	if token == "" || s.Owner == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(s.Owner), []byte(token)) == 1 ||
+		subtle.ConstantTimeCompare([]byte(s.Owner), []byte(hashOwner(token))) == 1
+}
+
+func hashOwner(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// AllFiles returns every file of the snippet, starting with its own content.
+func (s *Snippet) AllFiles() []File {
+	return append([]File{{Name: s.Filename, Language: s.Language, Content: s.Content}}, s.Files...)
+}
+
+// Who can see a snippet. Unlisted snippets can be opened by anyone with the
+// link, private ones only by their owner.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// NewSnippet is what a user supplies when creating a snippet.
+type NewSnippet struct {
+	Title    string
+	Content  string
+	Expires  time.Time // when the snippet expires, to the minute; zero for never
+	Tags     []string  // normalised tag names, see the create handler
+	Language string
+	Guessed  bool // Language was detected, not picked
+
+	BurnAfterReading bool
+	Password         string // plain text, Insert stores a bcrypt hash; empty for none
+
+	Visibility string // one of the Visibility constants
+	Owner      string // token of the creating session
+
+	Filename string // name of Content, optional for a single file
+	Files    []File // further files, stored after Content
+
+	ForkedFrom int // id of the snippet being forked, 0 for none
+
+	Attachments []Attachment // already in blob storage, ID and Created are ignored
+}
+
+// Revision is an immutable copy of a snippet's title and content, one is
+// written on create and on every edit.
+type Revision struct {
+	SnippetID int
+	Number    int
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// SnippetStore is the set of snippet operations the handlers depend on, so the
+// web app can run against MySQL or the in-memory store without knowing which.
+// Every method gives up when ctx is done; TimeoutStore adds a deadline.
+type SnippetStore interface {
+	Insert(ctx context.Context, n NewSnippet) (id int, slug string, err error)
+	Get(ctx context.Context, id int) (*Snippet, error)
+	GetBySlug(ctx context.Context, slug string) (*Snippet, error)
+	Latest(ctx context.Context) ([]*Snippet, error)
+	Page(ctx context.Context, tag string, before int, after int, size int) ([]*Snippet, bool, error)
+	Search(ctx context.Context, query string, limit int) ([]*Snippet, error)
+	Update(ctx context.Context, id int, title string, content string, language string, guessed bool) (int, error)
+	Revisions(ctx context.Context, id int) ([]*Revision, error)
+	GetRevision(ctx context.Context, id int, revision int) (*Revision, error)
+	Delete(ctx context.Context, id int) error
+	Trash(ctx context.Context, grace time.Duration, owner string) ([]*Snippet, error)
+	Restore(ctx context.Context, slug string, grace time.Duration, owner string) (int, error)
+	PurgeDeleted(ctx context.Context, grace time.Duration) (int, error)
+	PurgeExpired(ctx context.Context, limit int) (int, error)
+	Burn(ctx context.Context, id int) (*Snippet, error)
+	CheckPassword(ctx context.Context, id int, password string) error
+	Forks(ctx context.Context, id int) ([]*Snippet, error)
+	OrphanedBlobs(ctx context.Context, limit int) ([]string, error)
+	DeleteBlobs(ctx context.Context, keys []string) error
+}
+
+// expiresArg is the expires value Insert stores, NULL for never.
+func expiresArg(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Truncate(time.Minute)
+}
+
+// nullID is the value stored for an optional reference to another snippet,
+// NULL for 0.
+func nullID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// nullTime scans a nullable timestamp into t, NULL leaves it zero.
+type nullTime struct{ t *time.Time }
+
+func (n nullTime) Scan(value any) error {
+	var nt sql.NullTime
+	if err := nt.Scan(value); err != nil {
+		return err
+	}
+	*n.t = nt.Time
+	return nil
 }
 
 // model/repo/data access layer/dao
 type SnippetModel struct {
-	DB *sql.DB
+	DB         *sql.DB
+	SlugLength int // length of new slugs, DefaultSlugLength if 0
+
+	// Content and files longer than ContentThreshold bytes go to Blobs, the
+	// row keeps their first ContentThreshold bytes, for search, and the key.
+	// 0 keeps all content in the database
+	Blobs            blobs.Store
+	ContentThreshold int
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// Insert stores a new snippet under a random slug and returns its id and slug.
+// A slug that's already taken is retried with a fresh one.
+func (m *SnippetModel) Insert(ctx context.Context, n NewSnippet) (int, string, error) {
 
-	stmt := `insert into snippets (title, content, created, expires)
-	values(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-	r, err := m.DB.Exec(stmt, title, content, expires)
+	// hashing is slow on purpose, so it's done before the transaction starts
+	hash, err := hashPassword(n.Password)
+	if err != nil {
+		return 0, "", err
+	}
+	content, contentKey, err := offloadContent(ctx, m.Blobs, m.ContentThreshold, n.Content)
+	if err != nil {
+		return 0, "", err
+	}
+	files, fileKeys, err := offloadFiles(ctx, m.Blobs, m.ContentThreshold, n.Files)
+	if err != nil {
+		discardContent(ctx, m.Blobs, contentKey)
+		return 0, "", err
+	}
+	n.Content, n.Files = content, files
+	keys := append([]string{contentKey}, fileKeys...)
+
+	for attempt := 1; ; attempt++ {
+		slug, err := newSlug(m.SlugLength)
+		if err != nil {
+			discardContent(ctx, m.Blobs, keys...)
+			return 0, "", err
+		}
+		id, err := m.insert(ctx, n, hash, slug, contentKey, fileKeys)
+		if err != nil {
+			if isDuplicateSlug(err) && attempt < maxSlugAttempts {
+				continue
+			}
+			discardContent(ctx, m.Blobs, keys...)
+			return 0, "", err
+		}
+		return id, slug, nil
+	}
+}
+
+func (m *SnippetModel) insert(ctx context.Context, n NewSnippet, hash string, slug string, contentKey string, fileKeys []string) (int, error) {
+
+	// the snippet, its first revision and its tags are written together
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `insert into snippets (slug, title, content, language, language_guessed, burn_after_reading, password_hash, visibility, owner, created, expires, filename, forked_from, content_key)
+	values(?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?, ?, ?, ?)`
+	r, err := tx.ExecContext(ctx, stmt, slug, n.Title, n.Content, n.Language, n.Guessed, n.BurnAfterReading, hash, n.Visibility, n.Owner, expiresArg(n.Expires), n.Filename, nullID(n.ForkedFrom), contentKey)
 	if err != nil {
 		return 0, err
 	}
@@ -35,14 +259,72 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 		return 0, err
 	}
 
+	stmt = `insert into snippet_revisions (snippet_id, revision, title, content, content_key, created)
+	select id, revision, title, content, content_key, created from snippets where id = ?`
+	if _, err = tx.ExecContext(ctx, stmt, id); err != nil {
+		return 0, err
+	}
+
+	// every offloaded blob is tracked, so the reaper finds it once the snippet
+	// is purged
+	for _, key := range append([]string{contentKey}, fileKeys...) {
+		if key == "" {
+			continue
+		}
+		stmt = `insert into snippet_content_blobs (blob_key, snippet_id) values(?, ?)`
+		if _, err = tx.ExecContext(ctx, stmt, key, id); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, tag := range n.Tags {
+		if _, err = tx.ExecContext(ctx, `insert ignore into tags (name) values(?)`, tag); err != nil {
+			return 0, err
+		}
+		stmt = `insert into snippet_tags (snippet_id, tag_id) select ?, id from tags where name = ?`
+		if _, err = tx.ExecContext(ctx, stmt, id, tag); err != nil {
+			return 0, err
+		}
+	}
+
+	// the first file lives in the snippet itself, the rest are numbered from 1
+	for i, file := range n.Files {
+		stmt = `insert into snippet_files (snippet_id, position, name, language, content, content_key) values(?, ?, ?, ?, ?, ?)`
+		if _, err = tx.ExecContext(ctx, stmt, id, i+1, file.Name, file.Language, file.Content, fileKeys[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, a := range n.Attachments {
+		stmt = `insert into snippet_attachments (snippet_id, name, content_type, size, blob_key, created) values(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+		if _, err = tx.ExecContext(ctx, stmt, id, a.Name, a.ContentType, a.Size, a.Key); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
 	return int(id), nil
 }
 
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	return m.get(ctx, "id", id)
+}
+
+// GetBySlug is Get by the snippet's slug instead of its id.
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (*Snippet, error) {
+	return m.get(ctx, "slug", slug)
+}
+
+// get loads a live snippet and its tags by column, either id or slug.
+func (m *SnippetModel) get(ctx context.Context, column string, value any) (*Snippet, error) {
 
 	s := &Snippet{}
-	stmt := `select * from snippets where expires > UTC_TIMESTAMP() and id = ?`
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	var contentKey string
+	stmt := `select id, slug, title, content, language, language_guessed, burn_after_reading, password_hash <> '', visibility, owner, created, expires, revision, filename, coalesce(forked_from, 0), content_key
+	from snippets where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at is null and ` + column + ` = ?`
+	err := m.DB.QueryRowContext(ctx, stmt, value).Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Language, &s.Guessed, &s.BurnAfterReading, &s.Protected, &s.Visibility, &s.Owner, &s.Created, nullTime{&s.Expires}, &s.Revision, &s.Filename, &s.ForkedFrom, &contentKey)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// returning our own sentinel error to abstract the datastore specific errors.
@@ -51,16 +333,233 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 			return nil, err
 		}
 	}
+	s.Content, err = loadContent(ctx, m.Blobs, s.Content, contentKey)
+	if err != nil {
+		return nil, err
+	}
+	s.Tags, err = m.tags(ctx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.Files, err = m.files(ctx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.Attachments, err = m.attachments(ctx, s.ID)
+	if err != nil {
+		return nil, err
+	}
 	return s, nil
 }
 
+// tags returns the names of a snippet's tags in alphabetical order.
+func (m *SnippetModel) tags(ctx context.Context, id int) ([]string, error) {
+
+	tags := []string{}
+	stmt := `select t.name from tags t join snippet_tags st on st.tag_id = t.id
+	where st.snippet_id = ? order by t.name`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// files returns a snippet's files after the first one, in order.
+func (m *SnippetModel) files(ctx context.Context, id int) ([]File, error) {
+
+	files := []File{}
+	keys := []string{}
+	stmt := `select name, language, content, content_key from snippet_files where snippet_id = ? order by position`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f File
+		var key string
+		if err := rows.Scan(&f.Name, &f.Language, &f.Content, &key); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		files[i].Content, err = loadContent(ctx, m.Blobs, files[i].Content, keys[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// attachments returns the files uploaded with a snippet, in upload order.
+func (m *SnippetModel) attachments(ctx context.Context, id int) ([]Attachment, error) {
+
+	attachments := []Attachment{}
+	stmt := `select id, name, content_type, size, blob_key, created from snippet_attachments where snippet_id = ? order by id`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.ID, &a.Name, &a.ContentType, &a.Size, &a.Key, &a.Created); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// Update stores title and content as a new revision of the snippet and returns
+// the new revision number.
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, language string, guessed bool) (int, error) {
+
+	content, contentKey, err := offloadContent(ctx, m.Blobs, m.ContentThreshold, content)
+	if err != nil {
+		return 0, err
+	}
+	revision, err := m.update(ctx, id, title, content, contentKey, language, guessed)
+	if err != nil {
+		discardContent(ctx, m.Blobs, contentKey)
+		return 0, err
+	}
+	return revision, nil
+}
+
+// update is Update once the content is where it belongs.
+func (m *SnippetModel) update(ctx context.Context, id int, title string, content string, contentKey string, language string, guessed bool) (int, error) {
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// bumping the revision locks the row, so concurrent edits get distinct numbers
+	stmt := `update snippets set title = ?, content = ?, content_key = ?, language = ?, language_guessed = ?, revision = revision + 1
+	where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at is null and id = ?`
+	r, err := tx.ExecContext(ctx, stmt, title, content, contentKey, language, guessed, id)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, constants.ErrNoRecord
+	}
+
+	stmt = `insert into snippet_revisions (snippet_id, revision, title, content, content_key, created)
+	select id, revision, title, content, content_key, UTC_TIMESTAMP() from snippets where id = ?`
+	if _, err = tx.ExecContext(ctx, stmt, id); err != nil {
+		return 0, err
+	}
+
+	var revision int
+	err = tx.QueryRowContext(ctx, `select revision from snippets where id = ?`, id).Scan(&revision)
+	if err != nil {
+		return 0, err
+	}
+
+	if contentKey != "" {
+		stmt = `insert into snippet_content_blobs (blob_key, snippet_id) values(?, ?)`
+		if _, err = tx.ExecContext(ctx, stmt, contentKey, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// Revisions returns every revision of a live snippet, oldest first. Content
+// that was moved to blob storage is left empty, GetRevision loads it.
+func (m *SnippetModel) Revisions(ctx context.Context, id int) ([]*Revision, error) {
+
+	revisions := []*Revision{}
+	stmt := `select r.snippet_id, r.revision, r.title, r.content, r.created
+	from snippet_revisions r join snippets s on s.id = r.snippet_id
+	where (s.expires is null or s.expires > UTC_TIMESTAMP()) and s.deleted_at is null and r.snippet_id = ? order by r.revision`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rev := &Revision{}
+		err := rows.Scan(&rev.SnippetID, &rev.Number, &rev.Title, &rev.Content, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (m *SnippetModel) GetRevision(ctx context.Context, id int, revision int) (*Revision, error) {
+
+	rev := &Revision{}
+	var contentKey string
+	stmt := `select r.snippet_id, r.revision, r.title, r.content, r.content_key, r.created
+	from snippet_revisions r join snippets s on s.id = r.snippet_id
+	where (s.expires is null or s.expires > UTC_TIMESTAMP()) and s.deleted_at is null and r.snippet_id = ? and r.revision = ?`
+	err := m.DB.QueryRowContext(ctx, stmt, id, revision).Scan(&rev.SnippetID, &rev.Number, &rev.Title, &rev.Content, &contentKey, &rev.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, constants.ErrNoRecord
+		}
+		return nil, err
+	}
+	rev.Content, err = loadContent(ctx, m.Blobs, rev.Content, contentKey)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
 // returns 10 most recently created snippets
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context) ([]*Snippet, error) {
 
 	snippets := []*Snippet{}
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
-	rows, err := m.DB.Query(stmt)
+	stmt := `SELECT id, slug, title, content, created, expires, revision FROM snippets
+	WHERE (expires IS NULL OR expires > UTC_TIMESTAMP()) AND deleted_at IS NULL AND NOT burn_after_reading AND visibility = 'public' ORDER BY id DESC LIMIT 10`
+	rows, err := m.DB.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +568,7 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	for rows.Next() {
 		s := &Snippet{}
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Created, nullTime{&s.Expires}, &s.Revision)
 		if err != nil {
 			return nil, err
 		}
@@ -84,6 +583,231 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	return snippets, nil
 }
 
+// Page returns up to size live snippets, newest first, using the id as a
+// keyset cursor. With before > 0 only snippets older than that id are
+// returned, with after > 0 only newer ones. A non-empty tag limits the page to
+// snippets with that tag. more reports whether there are further snippets
+// past the page in the direction being paged.
+func (m *SnippetModel) Page(ctx context.Context, tag string, before int, after int, size int) ([]*Snippet, bool, error) {
+
+	stmt := `select id, slug, title, content, created, expires, revision from snippets
+	where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at is null and not burn_after_reading and visibility = 'public' and id < ?
+	and (? = '' or id in (select st.snippet_id from snippet_tags st join tags t on t.id = st.tag_id where t.name = ?))
+	order by id desc limit ?`
+	cursor := before
+	if after > 0 {
+		// walk forwards from the cursor, the rows get flipped back below
+		stmt = `select id, slug, title, content, created, expires, revision from snippets
+		where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at is null and not burn_after_reading and visibility = 'public' and id > ?
+		and (? = '' or id in (select st.snippet_id from snippet_tags st join tags t on t.id = st.tag_id where t.name = ?))
+		order by id asc limit ?`
+		cursor = after
+	} else if before <= 0 {
+		cursor = math.MaxInt32
+	}
+
+	// one extra row tells us whether there is another page
+	rows, err := m.DB.QueryContext(ctx, stmt, cursor, tag, tag, size+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+	for rows.Next() {
+		s := &Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Created, nullTime{&s.Expires}, &s.Revision)
+		if err != nil {
+			return nil, false, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	more := len(snippets) > size
+	if more {
+		snippets = snippets[:size]
+	}
+	if after > 0 {
+		slices.Reverse(snippets)
+	}
+	return snippets, more, nil
+}
+
+// Search returns up to limit live snippets matching any word of query, best
+// match first. A match in the title counts ten times as much as one in the
+// content. Needs the fulltext indexes from migration 0005.
+func (m *SnippetModel) Search(ctx context.Context, query string, limit int) ([]*Snippet, error) {
+
+	snippets := []*Snippet{}
+	terms := strings.Join(SearchTerms(query), " ")
+	if terms == "" {
+		return snippets, nil
+	}
+
+	stmt := `select id, slug, title, content, created, expires, revision from snippets
+	where match(title, content) against(?) and (expires is null or expires > UTC_TIMESTAMP()) and deleted_at is null and not burn_after_reading and password_hash = '' and visibility = 'public'
+	order by match(title) against(?) * 10 + match(content) against(?) desc, id desc limit ?`
+	rows, err := m.DB.QueryContext(ctx, stmt, terms, terms, terms, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Created, nullTime{&s.Expires}, &s.Revision)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Delete moves a live snippet to the trash. It stays restorable until the
+// grace period passed to Restore runs out.
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+
+	stmt := `update snippets set deleted_at = UTC_TIMESTAMP()
+	where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at is null and id = ?`
+	r, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return constants.ErrNoRecord
+	}
+	return nil
+}
+
+// Trash returns the snippets deleted within the last grace period that can
+// still be restored, most recently deleted first, of the snippets owner
+// created. Only the owner may restore a snippet, so nobody else sees it.
+func (m *SnippetModel) Trash(ctx context.Context, grace time.Duration, owner string) ([]*Snippet, error) {
+
+	snippets := []*Snippet{}
+	stmt := `select id, slug, title, content, created, expires, revision, deleted_at from snippets
+	where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	and owner <> '' and owner = ? order by deleted_at desc`
+	rows, err := m.DB.QueryContext(ctx, stmt, int(grace.Seconds()), owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Created, nullTime{&s.Expires}, &s.Revision, &s.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Restore takes the snippet with slug back out of the trash and returns its
+// id, as long as it was deleted within the last grace period and is one that
+// Trash lists for owner. Someone else's snippet is ErrNotOwner, unless it's
+// private, which is ErrNoRecord like a missing one.
+func (m *SnippetModel) Restore(ctx context.Context, slug string, grace time.Duration, owner string) (int, error) {
+
+	stmt := `update snippets set deleted_at = null
+	where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	and owner <> '' and owner = ? and slug = ?`
+	r, err := m.DB.ExecContext(ctx, stmt, int(grace.Seconds()), owner, slug)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, m.notRestored(ctx, slug, grace)
+	}
+
+	// MySQL has no returning, slugs never change so this is the same row
+	var id int
+	err = m.DB.QueryRowContext(ctx, `select id from snippets where slug = ?`, slug).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// notRestored tells why Restore found nothing to restore.
+func (m *SnippetModel) notRestored(ctx context.Context, slug string, grace time.Duration) error {
+
+	stmt := `select visibility from snippets
+	where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND) and slug = ?`
+	var visibility string
+	err := m.DB.QueryRowContext(ctx, stmt, int(grace.Seconds()), slug).Scan(&visibility)
+	return restoreError(visibility, err)
+}
+
+// restoreError is the error of a Restore that found nothing, given the
+// visibility of the restorable snippet with the slug, if there's one.
+func restoreError(visibility string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return constants.ErrNoRecord
+	case err != nil:
+		return err
+	case visibility == VisibilityPrivate:
+		return constants.ErrNoRecord
+	}
+	return constants.ErrNotOwner
+}
+
+// PurgeDeleted removes snippets that have been in the trash for longer than
+// grace, along with their revisions, and returns how many were removed.
+func (m *SnippetModel) PurgeDeleted(ctx context.Context, grace time.Duration) (int, error) {
+
+	stmt := `delete from snippets where deleted_at <= DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`
+	r, err := m.DB.ExecContext(ctx, stmt, int(grace.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// PurgeExpired permanently removes up to limit expired snippets, the ones that
+// expired first, along with their revisions and tags. It returns how many were
+// removed, fewer than limit means there are none left.
+func (m *SnippetModel) PurgeExpired(ctx context.Context, limit int) (int, error) {
+
+	stmt := `delete from snippets where expires <= UTC_TIMESTAMP() order by expires limit ?`
+	r, err := m.DB.ExecContext(ctx, stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
 // upside of writing all the code of sql - like connecting to db
 // is the it's non-magical and we can understand and
 // control exactly what is going on
@@ -93,3 +817,132 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 // processing forms : Post-Redirect-Get
 // get /snippet/create -> post snippet/create -> get snippet/view/:id
+
+// Burn deletes a burn after reading snippet and returns it as it was. Of any
+// number of concurrent callers only one gets the snippet, the rest get
+// ErrNoRecord. Tags aren't loaded.
+func (m *SnippetModel) Burn(ctx context.Context, id int) (*Snippet, error) {
+
+	// MySQL has no delete ... returning, so the row is locked while it's read
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s := &Snippet{}
+	var contentKey string
+	stmt := `select id, slug, title, content, language, language_guessed, burn_after_reading, visibility, created, expires, revision, filename, content_key from snippets
+	where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at is null and burn_after_reading and id = ? for update`
+	err = tx.QueryRowContext(ctx, stmt, id).Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Language, &s.Guessed, &s.BurnAfterReading, &s.Visibility, &s.Created, nullTime{&s.Expires}, &s.Revision, &s.Filename, &contentKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, constants.ErrNoRecord
+		}
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, `delete from snippets where id = ?`, id); err != nil {
+		return nil, err
+	}
+
+	// offloaded content is read before the delete commits: if that fails the
+	// snippet is still there, and the reaper only sees the blob once it's read
+	s.Content, err = loadContent(ctx, m.Blobs, s.Content, contentKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password is the one the
+// snippet was created with. Snippets without a password accept anything.
+func (m *SnippetModel) CheckPassword(ctx context.Context, id int, password string) error {
+
+	var hash string
+	stmt := `select password_hash from snippets where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at is null and id = ?`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return constants.ErrNoRecord
+		}
+		return err
+	}
+	return checkPassword(hash, password)
+}
+
+// Forks returns the live, listed snippets forked from a snippet, newest first.
+func (m *SnippetModel) Forks(ctx context.Context, id int) ([]*Snippet, error) {
+
+	snippets := []*Snippet{}
+	stmt := `select id, slug, title, created from snippets
+	where (expires is null or expires > UTC_TIMESTAMP()) and deleted_at is null and not burn_after_reading and visibility = 'public' and forked_from = ? order by id desc`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &Snippet{}
+		if err := rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Created); err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// OrphanedBlobs returns up to limit keys of blobs, attachments and offloaded
+// content, whose snippet has been purged. Once the blobs are deleted their
+// rows go with DeleteBlobs.
+func (m *SnippetModel) OrphanedBlobs(ctx context.Context, limit int) ([]string, error) {
+
+	keys := []string{}
+	stmt := `select blob_key from snippet_attachments where snippet_id is null
+	union all select blob_key from snippet_content_blobs where snippet_id is null limit ?`
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// DeleteBlobs removes the rows of orphaned blobs by key.
+func (m *SnippetModel) DeleteBlobs(ctx context.Context, keys []string) error {
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, key := range keys {
+		if _, err := tx.ExecContext(ctx, `delete from snippet_attachments where snippet_id is null and blob_key = ?`, key); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `delete from snippet_content_blobs where snippet_id is null and blob_key = ?`, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"snippetbox.tushar.net/internal/constants"
+)
+
+// TimeoutStore wraps a SnippetStore so that no call to it takes longer than
+// Timeout, and so that the error of a call cut short by its context is
+// constants.ErrTimeout or constants.ErrCanceled whatever the driver made of
+// it. Each call, with all the queries it runs, gets the full Timeout.
+type TimeoutStore struct {
+	Store   SnippetStore
+	Timeout time.Duration // 0 for no limit besides the caller's ctx
+}
+
+func (t *TimeoutStore) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, t.Timeout)
+}
+
+// ContextError tells a call that failed because ctx ended apart from one that
+// failed on its own, returning constants.ErrTimeout or constants.ErrCanceled
+// wrapping err for the former. Drivers report it differently: some return
+// ctx.Err(), lib/pq and SQLite their own "canceled" or "interrupted" errors.
+// Blob storage calls go through it too, the S3 client has errors of its own.
+func ContextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	// answers that came back before ctx ended, and errors already told apart
+	if errors.Is(err, constants.ErrNoRecord) || errors.Is(err, constants.ErrInvalidCredentials) || errors.Is(err, constants.ErrNotOwner) ||
+		errors.Is(err, constants.ErrTimeout) || errors.Is(err, constants.ErrCanceled) {
+		return err
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", constants.ErrTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", constants.ErrCanceled, err)
+}
+
+func (t *TimeoutStore) Insert(ctx context.Context, n NewSnippet) (int, string, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	id, slug, err := t.Store.Insert(ctx, n)
+	return id, slug, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) Get(ctx context.Context, id int) (*Snippet, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	s, err := t.Store.Get(ctx, id)
+	return s, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) GetBySlug(ctx context.Context, slug string) (*Snippet, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	s, err := t.Store.GetBySlug(ctx, slug)
+	return s, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) Latest(ctx context.Context) ([]*Snippet, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	snippets, err := t.Store.Latest(ctx)
+	return snippets, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) Page(ctx context.Context, tag string, before int, after int, size int) ([]*Snippet, bool, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	snippets, more, err := t.Store.Page(ctx, tag, before, after, size)
+	return snippets, more, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) Search(ctx context.Context, query string, limit int) ([]*Snippet, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	snippets, err := t.Store.Search(ctx, query, limit)
+	return snippets, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) Update(ctx context.Context, id int, title string, content string, language string, guessed bool) (int, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	revision, err := t.Store.Update(ctx, id, title, content, language, guessed)
+	return revision, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) Revisions(ctx context.Context, id int) ([]*Revision, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	revisions, err := t.Store.Revisions(ctx, id)
+	return revisions, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) GetRevision(ctx context.Context, id int, revision int) (*Revision, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	r, err := t.Store.GetRevision(ctx, id, revision)
+	return r, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) Delete(ctx context.Context, id int) error {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	return ContextError(ctx, t.Store.Delete(ctx, id))
+}
+
+func (t *TimeoutStore) Trash(ctx context.Context, grace time.Duration, owner string) ([]*Snippet, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	snippets, err := t.Store.Trash(ctx, grace, owner)
+	return snippets, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) Restore(ctx context.Context, slug string, grace time.Duration, owner string) (int, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	id, err := t.Store.Restore(ctx, slug, grace, owner)
+	return id, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) PurgeDeleted(ctx context.Context, grace time.Duration) (int, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	n, err := t.Store.PurgeDeleted(ctx, grace)
+	return n, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) PurgeExpired(ctx context.Context, limit int) (int, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	n, err := t.Store.PurgeExpired(ctx, limit)
+	return n, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) Burn(ctx context.Context, id int) (*Snippet, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	s, err := t.Store.Burn(ctx, id)
+	return s, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) CheckPassword(ctx context.Context, id int, password string) error {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	return ContextError(ctx, t.Store.CheckPassword(ctx, id, password))
+}
+
+func (t *TimeoutStore) Forks(ctx context.Context, id int) ([]*Snippet, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	snippets, err := t.Store.Forks(ctx, id)
+	return snippets, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) OrphanedBlobs(ctx context.Context, limit int) ([]string, error) {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	keys, err := t.Store.OrphanedBlobs(ctx, limit)
+	return keys, ContextError(ctx, err)
+}
+
+func (t *TimeoutStore) DeleteBlobs(ctx context.Context, keys []string) error {
+	ctx, cancel := t.context(ctx)
+	defer cancel()
+	return ContextError(ctx, t.Store.DeleteBlobs(ctx, keys))
+}
@@ -0,0 +1,344 @@
+package syntax
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// only the start of a snippet is looked at, that's plenty to tell languages
+// apart and keeps detection cheap for huge pastes
+const detectLimit = 16 * 1024
+
+// minScore is how many points a language needs before it's used as a guess.
+// testdata/detect has sample snippets, in one directory per language they
+// should be detected as, to check changes to the rules against.
+const minScore = 4
+
+// Detect guesses the language of a snippet. A shebang line wins, then a file
+// name in the title ("main.go", "Dockerfile"), then the language whose
+// characteristic tokens score highest. It returns "text" when nothing stands
+// out, so the result is always one of Languages.
+func Detect(title, content string) string {
+	if len(content) > detectLimit {
+		content = content[:detectLimit]
+	}
+	if lang := fromShebang(content); lang != "" {
+		return lang
+	}
+	if lang := fromTitle(title); lang != "" {
+		return lang
+	}
+	return fromTokens(content)
+}
+
+// interpreters maps the program named on a shebang line to a language.
+var interpreters = map[string]string{
+	"sh":      "bash",
+	"bash":    "bash",
+	"dash":    "bash",
+	"ksh":     "bash",
+	"zsh":     "bash",
+	"python":  "python",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+	"ruby":    "ruby",
+	"php":     "php",
+	"kotlin":  "kotlin",
+	"swift":   "swift",
+}
+
+func fromShebang(content string) string {
+	line, _, _ := strings.Cut(strings.TrimPrefix(content, "\ufeff"), "\n")
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	prog := path.Base(fields[0])
+	// #!/usr/bin/env -S python3 -u names the interpreter after the flags
+	if prog == "env" {
+		prog = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				prog = f
+				break
+			}
+		}
+	}
+	// python3, python3.12, ruby2.7
+	prog = strings.TrimRight(prog, "0123456789.")
+	return interpreters[prog]
+}
+
+// extensions maps file extensions, and a few well known file names, to a
+// language.
+var extensions = map[string]string{
+	".sh":        "bash",
+	".bash":      "bash",
+	".zsh":       "bash",
+	".c":         "c",
+	".h":         "c",
+	".cc":        "c++",
+	".cpp":       "c++",
+	".cxx":       "c++",
+	".hpp":       "c++",
+	".hh":        "c++",
+	".cs":        "c#",
+	".css":       "css",
+	".diff":      "diff",
+	".patch":     "diff",
+	".go":        "go",
+	".html":      "html",
+	".htm":       "html",
+	".java":      "java",
+	".js":        "javascript",
+	".mjs":       "javascript",
+	".cjs":       "javascript",
+	".jsx":       "javascript",
+	".json":      "json",
+	".kt":        "kotlin",
+	".kts":       "kotlin",
+	".md":        "markdown",
+	".markdown":  "markdown",
+	".php":       "php",
+	".py":        "python",
+	".rb":        "ruby",
+	".rs":        "rust",
+	".sql":       "sql",
+	".swift":     "swift",
+	".ts":        "typescript",
+	".tsx":       "typescript",
+	".yml":       "yaml",
+	".yaml":      "yaml",
+	"dockerfile": "dockerfile",
+}
+
+// fileNameRX picks out words in a title that look like file names.
+var fileNameRX = regexp.MustCompile(`[\w.+-]*\.[A-Za-z]+\b|\b(?i:dockerfile)\b`)
+
+func fromTitle(title string) string {
+	for _, name := range fileNameRX.FindAllString(title, -1) {
+		name = strings.ToLower(name)
+		if lang, ok := extensions[name]; ok {
+			return lang
+		}
+		if lang := extensions[path.Ext(name)]; lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+// rule is a token or construct typical of a language. Each rule that matches
+// somewhere in the content adds its weight to the language's score once, so
+// long snippets don't outscore short ones just by repeating themselves.
+type rule struct {
+	rx     *regexp.Regexp
+	weight int
+}
+
+func rules(weighted ...any) []rule {
+	rs := []rule{}
+	for i := 0; i < len(weighted); i += 2 {
+		rs = append(rs, rule{regexp.MustCompile("(?m)" + weighted[i].(string)), weighted[i+1].(int)})
+	}
+	return rs
+}
+
+// heuristics are checked in this order, the first language with the highest
+// score wins a tie.
+var heuristics = []struct {
+	lang  string
+	rules []rule
+}{
+	{"diff", rules(
+		`^diff --git `, 5,
+		`^@@ -\d+(,\d+)? \+\d+(,\d+)? @@`, 5,
+		`^--- \S`, 1,
+		`^\+\+\+ \S`, 1,
+	)},
+	{"html", rules(
+		`(?i)<!doctype html`, 8,
+		`(?i)<(html|head|body|div|span|p|a|ul|ol|li|table|form|h[1-6])\b[^>]*>`, 2,
+		`</(html|head|body|div|span|p|a|ul|ol|li|table|form|h[1-6])>`, 3,
+	)},
+	{"php", rules(
+		`<\?php`, 10,
+		`\$\w+\s*=[^=]`, 2,
+		`\bfunction \w+\(\$`, 3,
+		`\$this->`, 3,
+	)},
+	{"dockerfile", rules(
+		`^FROM \S+`, 4,
+		`^(RUN|COPY|ADD|CMD|ENTRYPOINT|WORKDIR|EXPOSE|ENV|ARG) `, 3,
+	)},
+	{"go", rules(
+		`^package \w+\s*$`, 4,
+		`^import \($`, 2,
+		`^func (\(\w+ \*?\w+(\[.*\])?\) )?\w+\(`, 3,
+		`\w :?= `, 1,
+		`\bfmt\.\w+\(`, 2,
+		`\bif err != nil\b`, 3,
+		`\bgo func\b|\bchan \w+|\bdefer \w+`, 2,
+	)},
+	{"rust", rules(
+		`\bfn \w+(<.*>)?\(`, 3,
+		`\blet mut\b`, 3,
+		`\b(println|format|vec|panic)!\(`, 4,
+		`^\s*use (std|crate)::`, 4,
+		`^\s*impl\b`, 2,
+		`\bpub (fn|struct|enum)\b`, 2,
+		`&str\b|&mut\b`, 2,
+	)},
+	{"c++", rules(
+		`^#include <(iostream|vector|string|map|memory|algorithm|unordered_map)>`, 5,
+		`\bstd::`, 3,
+		`\bcout\s*<<|\bcin\s*>>`, 3,
+		`\btemplate\s*<`, 3,
+		`^\s*class \w+\s*(:\s*public \w+\s*)?\{`, 1,
+		`^using namespace \w+;`, 3,
+	)},
+	{"c", rules(
+		`^#include [<"]\w+\.h[>"]`, 3,
+		`\bint main\s*\(`, 2,
+		`\bprintf\(`, 2,
+		`\b(malloc|free|sizeof)\(`, 2,
+		`^\s*(static )?(int|void|char|double|float) \*?\w+\(`, 1,
+		`^#define \w+`, 2,
+		`\bstruct \w+ \*`, 2,
+	)},
+	{"c#", rules(
+		`^using System(\.\w+)*;`, 5,
+		`\bConsole\.Write(Line)?\(`, 4,
+		`\{ get; (private )?set; \}`, 3,
+		`^\s*namespace [\w.]+`, 1,
+		`\b(public|private|internal) (static )?(async )?(class|void|string|int|Task)\b`, 1,
+		`\bvar \w+ = new\b`, 2,
+	)},
+	{"java", rules(
+		`\bSystem\.out\.print(ln)?\(`, 4,
+		`^import java(x)?\.`, 4,
+		`\bpublic (static |final |abstract )*(class|interface|void|enum)\b`, 3,
+		`\bString\[\] args\b`, 3,
+		`@Override\b`, 2,
+		`\bprivate (static )?final\b`, 1,
+	)},
+	{"kotlin", rules(
+		`\bfun \w+\(`, 3,
+		`^import kotlin`, 4,
+		`\bval \w+(: \w+)? =`, 2,
+		`\bdata class\b`, 3,
+		`\bprintln\(`, 1,
+	)},
+	{"swift", rules(
+		`^import (Foundation|UIKit|SwiftUI)\b`, 5,
+		`\bfunc \w+\(.*\)( -> \w+)?\s*\{`, 2,
+		`\b(guard|if) let\b`, 3,
+		`\blet \w+(: \w+)? = `, 1,
+		`\bprint\(`, 1,
+	)},
+	{"typescript", rules(
+		`\w\??: (string|number|boolean|any|void|unknown)\b`, 3,
+		`^\s*(export )?(interface|type) \w+(<.*>)? (\{|=)`, 3,
+		`^\s*import .* from ['"]`, 1,
+		`\b(const|let) \w+ = `, 1,
+		`=> `, 1,
+		`\bas const\b|\breadonly\b`, 2,
+	)},
+	{"javascript", rules(
+		`\b(const|let|var) \w+ = `, 1,
+		`=> `, 1,
+		`\bfunction\s*\w*\s*\(`, 2,
+		`\bconsole\.log\(`, 3,
+		`\b(document|window)\.\w+`, 2,
+		`\brequire\(['"]`, 2,
+		`\bmodule\.exports\b|^export (default )?`, 2,
+		`===|!==`, 1,
+	)},
+	{"python", rules(
+		`^\s*def \w+\(.*\)( -> [\w\[\], .]+)?:\s*$`, 4,
+		`^\s*(from [\w.]+ )?import \w+`, 1,
+		`\bself\b`, 1,
+		`^\s*class \w+(\(.*\))?:\s*$`, 2,
+		`\belif\b`, 2,
+		`^if __name__ == ['"]__main__['"]:`, 4,
+		`\b(None|True|False)\b`, 1,
+		`^\s*(for|if|while|with) .*:\s*$`, 2,
+	)},
+	{"ruby", rules(
+		`^\s*def \w+[?!]?(\(.*\))?\s*$`, 2,
+		`^\s*end\s*$`, 2,
+		`\bputs\b`, 2,
+		`^\s*require(_relative)? ['"]`, 2,
+		`\.each( do)? \|\w+\|| do \|\w+(, \w+)*\|`, 3,
+		`\battr_(accessor|reader|writer)\b`, 3,
+	)},
+	{"bash", rules(
+		`^\s*(if|while|until) \[\[? `, 3,
+		`^\s*fi\s*$`, 3,
+		`^\s*done\s*$`, 2,
+		`^\s*(export|local|readonly) \w+=`, 2,
+		`^\s*echo\b`, 1,
+		`\|\s*(grep|awk|sed|xargs|sort|uniq|cut|tr)\b`, 2,
+		`\$\{\w+`, 1,
+		`\$\(`, 1,
+	)},
+	{"sql", rules(
+		`(?i)^\s*select\b[\s\S]*?\bfrom\b`, 3,
+		`(?i)^\s*(insert into|update \w+ set|delete from|create (table|index|view)|alter table|drop table)\b`, 4,
+		`(?i)\bwhere\b`, 1,
+		`(?i)\b(inner join|left join|group by|order by)\b`, 2,
+	)},
+	{"css", rules(
+		`^\s*[\w.#:\[\]="*>+~, -]+\{\s*$`, 1,
+		`^\s*-?[a-z-]+\s*:\s*[^;{}]+;\s*$`, 2,
+		`^\s*@(media|import|font-face|keyframes)\b`, 3,
+		`\b\d+(px|em|rem|vh|vw)\b`, 2,
+		`#[0-9a-fA-F]{3}([0-9a-fA-F]{3})?\b`, 1,
+	)},
+	{"markdown", rules(
+		`^#{1,6} \S`, 2,
+		"^```", 3,
+		`\[[^\]\n]+\]\([^)\n]+\)`, 3,
+		`^\s*[-*] \S`, 1,
+		`\*\*\S[^*\n]*\*\*`, 1,
+	)},
+	{"yaml", rules(
+		`^---\s*$`, 2,
+		`^[\w-]+:\s*$`, 2,
+		`^\s+[\w-]+: \S`, 1,
+		`^\s*- [\w-]+: `, 2,
+		`^[\w-]+: \S`, 1,
+	)},
+}
+
+func fromTokens(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return "text"
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	best, bestScore := "text", minScore-1
+	for _, h := range heuristics {
+		score := 0
+		for _, r := range h.rules {
+			if r.rx.MatchString(content) {
+				score += r.weight
+			}
+		}
+		if score > bestScore {
+			best, bestScore = h.lang, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,32 @@
+package syntax
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDetect runs Detect on every sample in testdata/detect, which should
+// come out as the language named by its directory.
+func TestDetect(t *testing.T) {
+	samples, err := filepath.Glob("testdata/detect/*/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) == 0 {
+		t.Fatal("no samples in testdata/detect")
+	}
+
+	for _, sample := range samples {
+		lang := filepath.Base(filepath.Dir(sample))
+		t.Run(lang+"/"+filepath.Base(sample), func(t *testing.T) {
+			content, err := os.ReadFile(sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Detect("", string(content)); got != lang {
+				t.Errorf("got %q; want %q", got, lang)
+			}
+		})
+	}
+}
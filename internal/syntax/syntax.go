@@ -0,0 +1,120 @@
+// Package syntax renders snippet content as syntax highlighted HTML with
+// chroma. The markup only uses css classes, the colours come from a
+// stylesheet generated for each of the supported styles, so nothing inline
+// is needed and it works under the app's Content-Security-Policy.
+package syntax
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Language is an entry in the language picker. Name is the chroma lexer name
+// stored with the snippet, Label is what the user sees.
+type Language struct {
+	Name  string
+	Label string
+}
+
+// Languages lists the languages offered when creating a snippet. Snippets
+// from before languages existed have an empty name, which is plain text too.
+var Languages = []Language{
+	{"text", "Plain text"},
+	{"bash", "Bash"},
+	{"c", "C"},
+	{"c++", "C++"},
+	{"c#", "C#"},
+	{"css", "CSS"},
+	{"diff", "Diff"},
+	{"dockerfile", "Dockerfile"},
+	{"go", "Go"},
+	{"html", "HTML"},
+	{"java", "Java"},
+	{"javascript", "JavaScript"},
+	{"json", "JSON"},
+	{"kotlin", "Kotlin"},
+	{"markdown", "Markdown"},
+	{"php", "PHP"},
+	{"python", "Python"},
+	{"ruby", "Ruby"},
+	{"rust", "Rust"},
+	{"sql", "SQL"},
+	{"swift", "Swift"},
+	{"typescript", "TypeScript"},
+	{"yaml", "YAML"},
+}
+
+// Styles are the colour schemes a user can pick, DefaultStyle is used until
+// they do.
+var Styles = []string{"github", "monokai", "dracula", "solarized-light"}
+
+const DefaultStyle = "github"
+
+var formatter = html.New(html.WithClasses(true), html.TabWidth(4))
+
+// Supported reports whether name is one of Languages.
+func Supported(name string) bool {
+	for _, l := range Languages {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Label returns the display name for a language, "Plain text" for unknown ones.
+func Label(name string) string {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l.Label
+		}
+	}
+	return "Plain text"
+}
+
+// Code returns content as a highlighted <pre> block. Content is escaped the
+// same way html/template would if the language is unknown or lexing fails.
+func Code(content, language string) template.HTML {
+	lexer := lexers.Fallback
+	if language != "" {
+		if l := lexers.Get(language); l != nil {
+			lexer = l
+		}
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err == nil {
+		var b bytes.Buffer
+		// the style only matters for inline styles, with classes the css decides
+		if err = formatter.Format(&b, styles.Fallback, iterator); err == nil {
+			return template.HTML(b.String())
+		}
+	}
+	return template.HTML("<pre class=\"chroma\"><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+}
+
+// CSS returns the stylesheet for one of Styles.
+func CSS(style string) ([]byte, error) {
+	if !isStyle(style) {
+		return nil, fmt.Errorf("syntax: unknown style %q", style)
+	}
+	var b bytes.Buffer
+	if err := formatter.WriteCSS(&b, styles.Get(style)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func isStyle(style string) bool {
+	for _, s := range Styles {
+		if s == style {
+			return true
+		}
+	}
+	return false
+}
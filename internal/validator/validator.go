@@ -1,10 +1,20 @@
 package validator
 
 import (
+	"regexp"
+	"slices"
 	"strings"
 	"unicode/utf8"
 )
 
+// TagRX matches a tag name: lower case letters, digits and a few separators,
+// starting with a letter or digit.
+var TagRX = regexp.MustCompile("^[a-z0-9][a-z0-9._+-]*$")
+
+// FileNameRX matches a file name without a directory, like main.go, go.mod or
+// .gitignore, but not "." or "..".
+var FileNameRX = regexp.MustCompile(`^\.?[A-Za-z0-9_+-][A-Za-z0-9._+-]*$`)
+
 // Define a new Validator type which contains a map of validation errors for our
 // form fields.
 type Validator struct {
@@ -45,6 +55,11 @@ func MaxChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) <= n
 }
 
+// MinChars() returns true if a value contains at least n characters.
+func MinChars(value string, n int) bool {
+	return utf8.RuneCountInString(value) >= n
+}
+
 // PermittedInt() returns true if a value is in a list of permitted integers.
 func PermittedInt(value int, permittedValues ...int) bool {
 	for i := range permittedValues {
@@ -54,3 +69,29 @@ func PermittedInt(value int, permittedValues ...int) bool {
 	}
 	return false
 }
+
+// PermittedValue() returns true if a value is in a list of permitted values.
+func PermittedValue[T comparable](value T, permittedValues ...T) bool {
+	return slices.Contains(permittedValues, value)
+}
+
+// Matches() returns true if a value matches a provided compiled regular
+// expression pattern.
+func Matches(value string, rx *regexp.Regexp) bool {
+	return rx.MatchString(value)
+}
+
+// MaxItems() returns true if a list contains no more than n items.
+func MaxItems[T any](values []T, n int) bool {
+	return len(values) <= n
+}
+
+// All() returns true if ok returns true for every value in a list.
+func All[T any](values []T, ok func(T) bool) bool {
+	for _, value := range values {
+		if !ok(value) {
+			return false
+		}
+	}
+	return true
+}